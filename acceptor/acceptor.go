@@ -1,7 +1,7 @@
 package acceptor
 
 import (
-	"github.com/gobwas/ws"
+	"io"
 	"net"
 	"sync"
 )
@@ -22,50 +22,64 @@ type Acceptor struct {
 	once   sync.Once
 	done   chan struct{}
 	schema Schema
+
+	mu        sync.Mutex
+	listeners []io.Closer
 }
 
 func (acceptor *Acceptor) Schema() Schema {
 	return acceptor.schema
 }
 
+// Shutdown stops accepting and closes the listeners
 func (acceptor *Acceptor) Shutdown() {
 	acceptor.once.Do(func() {
 		close(acceptor.done)
+
+		acceptor.mu.Lock()
+		defer acceptor.mu.Unlock()
+		for _, listener := range acceptor.listeners {
+			_ = listener.Close()
+		}
 	})
 }
 func (acceptor *Acceptor) ReactorSupported() bool {
 	return true
 }
 
-func NewAcceptor(schema Schema, options Options) Instance {
-	acceptor := &Acceptor{
-		schema: schema,
-		once:   sync.Once{},
-		done:   make(chan struct{}),
+// Done returns the channel which is closed when shutdown
+func (acceptor *Acceptor) Done() <-chan struct{} {
+	return acceptor.done
+}
+
+// AddListener keeps the listener which will be closed when shutdown
+func (acceptor *Acceptor) AddListener(listener io.Closer) {
+	acceptor.mu.Lock()
+	defer acceptor.mu.Unlock()
+	acceptor.listeners = append(acceptor.listeners, listener)
+}
+
+// stopped returns true if the acceptor is shutdown
+func (acceptor *Acceptor) stopped() bool {
+	select {
+	case <-acceptor.done:
+		return true
+	default:
+		return false
 	}
+}
 
-	if schema.Protocol == TCP {
-		return &TCPAcceptor{
-			Acceptor: acceptor,
-			options:  options,
-		}
-	} else if schema.Protocol == WEBSOCKET {
-		return &WebsocketAcceptor{
-			Acceptor: acceptor,
-			options:  options,
-			upgrade: ws.Upgrader{
-				OnHeader: func(key, value []byte) (err error) {
-					//log.Printf("non-websocket header: %q=%q", key, value)
-					return
-				},
-			},
-		}
-	} else if schema.Protocol == QUIC {
-		return &QUICAcceptor{
-			Acceptor: acceptor,
-			options:  options,
-		}
+// NewBaseAcceptor returns the base acceptor of the schema, which is embedded by the acceptors
+func NewBaseAcceptor(schema Schema) *Acceptor {
+	return &Acceptor{
+		schema: schema,
+		done:   make(chan struct{}),
 	}
+}
 
-	return nil
+// NewAcceptor returns the acceptor of the registered protocol, returns nil if the protocol is not registered,
+// use New to get the error
+func NewAcceptor(schema Schema, options Options) Instance {
+	instance, _ := New(schema, options)
+	return instance
 }
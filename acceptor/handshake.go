@@ -0,0 +1,175 @@
+package acceptor
+
+import (
+	"fmt"
+	"github.com/gobwas/ws"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// PropertyRequestURI is the property key of the request uri of websocket upgrade request
+	PropertyRequestURI = "ws.uri"
+	// PropertyPath is the property key of the path of websocket upgrade request
+	PropertyPath = "ws.path"
+	// PropertyQuery is the property key of the raw query of websocket upgrade request
+	PropertyQuery = "ws.query"
+	// PropertyProtocol is the property key of the subprotocol selected by HandshakeOptions.Protocol
+	PropertyProtocol = "ws.protocol"
+	// propertyHeaderPrefix is the prefix of the property keys of the selected headers
+	propertyHeaderPrefix = "ws.header."
+)
+
+// PropertyHeader returns the property key of the header of websocket upgrade request
+func PropertyHeader(name string) string {
+	return propertyHeaderPrefix + http.CanonicalHeaderKey(name)
+}
+
+// HandshakeOptions represents the options of the websocket upgrade
+type HandshakeOptions struct {
+	// Path restricts the path of upgrade request, empty means any path
+	Path string
+
+	// CheckOrigin returns false to reject the request with 403, nil means any origin
+	CheckOrigin func(origin string) bool
+
+	// Headers is the names of the headers which are copied to the connection properties, see PropertyHeader
+	Headers []string
+
+	// OnHandshake is called before upgrade, the request is rejected if it returns error,
+	// the status code is 403 unless the error is *HandshakeError
+	OnHandshake func(handshake *Handshake) error
+
+	// Protocol selects the subprotocol, the first accepted one in the order of client preference is saved to PropertyProtocol,
+	// nil means no subprotocol
+	Protocol func(protocol string) bool
+}
+
+// Handshake represents the websocket upgrade request
+type Handshake struct {
+	// URI is the request uri, like /ws?token=xxx
+	URI      string
+	Path     string
+	RawQuery string
+	Query    url.Values
+	Host     string
+	Header   http.Header
+	// Protocol is the selected subprotocol, which is empty before upgraded
+	Protocol string
+}
+
+// Origin returns the origin header
+func (handshake *Handshake) Origin() string {
+	return handshake.Header.Get("Origin")
+}
+
+// BearerToken returns the token of authorization header
+func (handshake *Handshake) BearerToken() string {
+	auth := handshake.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		return auth[7:]
+	}
+	return ""
+}
+
+// Cookie returns the named cookie, or nil if not found
+func (handshake *Handshake) Cookie(name string) *http.Cookie {
+	request := http.Request{Header: handshake.Header}
+	cookie, err := request.Cookie(name)
+	if err != nil {
+		return nil
+	}
+	return cookie
+}
+
+// HandshakeError rejects the upgrade request with the status code
+type HandshakeError struct {
+	Status int
+	Reason string
+}
+
+func (err *HandshakeError) Error() string {
+	return fmt.Sprintf("handshake rejected: %d %s", err.Status, err.Reason)
+}
+
+// NewHandshakeError returns a new HandshakeError
+func NewHandshakeError(status int, reason string) *HandshakeError {
+	return &HandshakeError{Status: status, Reason: reason}
+}
+
+// newUpgrader returns the upgrader of one connection, the handshake is filled while upgrading
+func newUpgrader(options HandshakeOptions, handshake *Handshake) ws.Upgrader {
+	var protocol func([]byte) bool
+	if options.Protocol != nil {
+		protocol = func(p []byte) bool {
+			return options.Protocol(string(p))
+		}
+	}
+	return ws.Upgrader{
+		Protocol: protocol,
+		OnRequest: func(uri []byte) error {
+			u, err := url.ParseRequestURI(string(uri))
+			if err != nil {
+				return ws.RejectConnectionError(ws.RejectionStatus(http.StatusBadRequest), ws.RejectionReason("invalid request uri"))
+			}
+			handshake.URI = string(uri)
+			handshake.Path = u.Path
+			handshake.RawQuery = u.RawQuery
+			handshake.Query = u.Query()
+			return nil
+		},
+		OnHost: func(host []byte) error {
+			handshake.Host = string(host)
+			return nil
+		},
+		OnHeader: func(key, value []byte) error {
+			handshake.Header.Add(string(key), string(value))
+			return nil
+		},
+		OnBeforeUpgrade: func() (ws.HandshakeHeader, error) {
+			if err := options.verify(handshake); err != nil {
+				return nil, ws.RejectConnectionError(ws.RejectionStatus(err.Status), ws.RejectionReason(err.Reason))
+			}
+			return nil, nil
+		},
+	}
+}
+
+// verify checks the path, origin and calls the hook, returns the error with status code if rejected
+func (options HandshakeOptions) verify(handshake *Handshake) *HandshakeError {
+	if options.Path != "" && options.Path != handshake.Path {
+		return NewHandshakeError(http.StatusNotFound, "path not found")
+	}
+	if options.CheckOrigin != nil && !options.CheckOrigin(handshake.Origin()) {
+		return NewHandshakeError(http.StatusForbidden, "origin not allowed")
+	}
+	if options.OnHandshake == nil {
+		return nil
+	}
+	if err := options.OnHandshake(handshake); err != nil {
+		if rejected, ok := err.(*HandshakeError); ok {
+			return rejected
+		}
+		return NewHandshakeError(http.StatusForbidden, err.Error())
+	}
+	return nil
+}
+
+// properties returns the properties of the connection upgraded by the handshake
+func (handshake *Handshake) properties(options HandshakeOptions) map[string]string {
+	properties := map[string]string{
+		PropertyRequestURI: handshake.URI,
+		PropertyPath:       handshake.Path,
+		PropertyQuery:      handshake.RawQuery,
+	}
+	if handshake.Protocol != "" {
+		properties[PropertyProtocol] = handshake.Protocol
+	}
+	for _, name := range options.Headers {
+		if value := handshake.Header.Get(name); value != "" {
+			properties[PropertyHeader(name)] = value
+		}
+	}
+	return properties
+}
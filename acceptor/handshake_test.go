@@ -0,0 +1,64 @@
+package acceptor
+
+import (
+	"bufio"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestHandshake(t *testing.T) {
+	handshake := &Handshake{URI: "/ws?room=1", Path: "/ws", RawQuery: "room=1", Header: http.Header{}}
+	handshake.Header.Set("Authorization", "Bearer secret")
+	handshake.Header.Set("Cookie", "session=abc")
+	handshake.Header.Set("Origin", "https://example.com")
+
+	assert.Equal(t, "secret", handshake.BearerToken())
+	assert.Equal(t, "abc", handshake.Cookie("session").Value)
+	assert.Nil(t, handshake.Cookie("none"))
+	assert.Equal(t, "https://example.com", handshake.Origin())
+
+	properties := handshake.properties(HandshakeOptions{Headers: []string{"authorization", "X-Missing"}})
+	assert.Equal(t, "/ws?room=1", properties[PropertyRequestURI])
+	assert.Equal(t, "/ws", properties[PropertyPath])
+	assert.Equal(t, "room=1", properties[PropertyQuery])
+	assert.Equal(t, "Bearer secret", properties[PropertyHeader("Authorization")])
+	assert.NotContains(t, properties, PropertyHeader("X-Missing"))
+	assert.NotContains(t, properties, PropertyProtocol)
+
+	handshake.Protocol = "cbor"
+	assert.Equal(t, "cbor", handshake.properties(HandshakeOptions{})[PropertyProtocol])
+}
+
+func TestNewUpgrader(t *testing.T) {
+	options := HandshakeOptions{
+		Path: "/ws",
+		OnHandshake: func(handshake *Handshake) error {
+			return NewHandshakeError(http.StatusUnauthorized, "bad token")
+		},
+	}
+
+	for path, status := range map[string]int{"/other": http.StatusNotFound, "/ws": http.StatusUnauthorized} {
+		server, client := net.Pipe()
+		go func() {
+			upgrader := newUpgrader(options, &Handshake{Header: http.Header{}})
+			_, _ = upgrader.Upgrade(server)
+			_ = server.Close()
+		}()
+
+		request, _ := http.NewRequest(http.MethodGet, "http://localhost"+path, nil)
+		request.Header.Set("Upgrade", "websocket")
+		request.Header.Set("Connection", "Upgrade")
+		request.Header.Set("Sec-WebSocket-Version", "13")
+		request.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		go func() {
+			_ = request.Write(client)
+		}()
+
+		response, err := http.ReadResponse(bufio.NewReader(client), request)
+		assert.Nil(t, err)
+		assert.Equal(t, status, response.StatusCode)
+		_ = client.Close()
+	}
+}
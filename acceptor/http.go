@@ -0,0 +1,95 @@
+package acceptor
+
+import (
+	"bufio"
+	"github.com/ebar-go/znet/codec"
+	"github.com/gobwas/ws"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// WebsocketHandler represents the websocket acceptor mounted on an existing http server,
+// it upgrades the requests by hijacking the connections instead of listening on its own port
+type WebsocketHandler struct {
+	*Acceptor
+	options Options
+
+	mu       sync.RWMutex
+	onAccept func(conn net.Conn)
+}
+
+// NewWebsocketHandler returns a new WebsocketHandler, the schema address is the path of the endpoint
+func NewWebsocketHandler(schema Schema, options Options) *WebsocketHandler {
+	return &WebsocketHandler{
+		Acceptor: NewBaseAcceptor(schema),
+		options:  options,
+	}
+}
+
+// ReactorSupported returns false, because the hijacked connection may be tls or has buffered data
+func (handler *WebsocketHandler) ReactorSupported() bool {
+	return false
+}
+
+// Listen keeps the callback, the connections are accepted by ServeHTTP
+func (handler *WebsocketHandler) Listen(onAccept func(conn net.Conn)) error {
+	handler.mu.Lock()
+	handler.onAccept = onAccept
+	handler.mu.Unlock()
+	return nil
+}
+
+// ServeHTTP upgrades the request and hands the connection to the server,
+// it responds 503 if the server is not running
+func (handler *WebsocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	handler.mu.RLock()
+	onAccept := handler.onAccept
+	handler.mu.RUnlock()
+	if onAccept == nil || handler.stopped() {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+
+	handshake := &Handshake{
+		URI:      r.RequestURI,
+		Path:     r.URL.Path,
+		RawQuery: r.URL.RawQuery,
+		Query:    r.URL.Query(),
+		Host:     r.Host,
+		Header:   r.Header.Clone(),
+	}
+	if err := handler.options.Handshake.verify(handshake); err != nil {
+		http.Error(w, err.Reason, err.Status)
+		return
+	}
+
+	upgrader := ws.HTTPUpgrader{Timeout: handler.options.HandshakeTimeout, Protocol: handler.options.Handshake.Protocol}
+	conn, rw, hs, err := upgrader.Upgrade(r, w)
+	if err != nil {
+		log.Printf("upgrade(\"%s\") error(%v)", r.RemoteAddr, err)
+		if conn != nil {
+			_ = conn.Close()
+		}
+		return
+	}
+	handshake.Protocol = hs.Protocol
+	// the frames sent right after the upgrade request may be buffered by the http server
+	if rw.Reader.Buffered() > 0 {
+		conn = &bufferedConn{Conn: conn, reader: rw.Reader}
+	}
+
+	onAccept(codec.NewWebsocketDecoderWithProperties(conn, handler.options.Websocket,
+		handshake.properties(handler.options.Handshake)))
+}
+
+// bufferedConn reads the data buffered by the http server first
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (conn *bufferedConn) Read(p []byte) (int, error) {
+	return conn.reader.Read(p)
+}
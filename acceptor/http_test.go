@@ -0,0 +1,48 @@
+package acceptor
+
+import (
+	"context"
+	"github.com/gobwas/ws"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebsocketHandler(t *testing.T) {
+	options := DefaultOptions()
+	options.Handshake.OnHandshake = func(handshake *Handshake) error {
+		if handshake.Query.Get("token") != "secret" {
+			return NewHandshakeError(http.StatusUnauthorized, "bad token")
+		}
+		return nil
+	}
+	handler := NewWebsocketHandler(NewWebSocketSchema("/ws"), options)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	url := "ws" + server.URL[len("http"):] + "/ws"
+
+	// not running
+	_, _, _, err := ws.Dial(context.Background(), url)
+	assert.Equal(t, ws.StatusError(http.StatusServiceUnavailable), err)
+
+	accepted := make(chan net.Conn, 1)
+	assert.Nil(t, handler.Listen(func(conn net.Conn) {
+		accepted <- conn
+	}))
+
+	_, _, _, err = ws.Dial(context.Background(), url)
+	assert.Equal(t, ws.StatusError(http.StatusUnauthorized), err)
+
+	conn, _, _, err := ws.Dial(context.Background(), url+"?token=secret")
+	assert.Nil(t, err)
+	defer conn.Close()
+	serverConn := <-accepted
+	assert.Equal(t, "token=secret", serverConn.(interface{ Properties() map[string]string }).Properties()[PropertyQuery])
+	_ = serverConn.Close()
+
+	handler.Shutdown()
+	_, _, _, err = ws.Dial(context.Background(), url)
+	assert.Equal(t, ws.StatusError(http.StatusServiceUnavailable), err)
+}
@@ -1,6 +1,10 @@
 package acceptor
 
 import (
+	"crypto/tls"
+	"github.com/ebar-go/znet/codec"
+	"github.com/lucas-clemente/quic-go"
+	"os"
 	"runtime"
 	"time"
 )
@@ -11,21 +15,101 @@ type Options struct {
 	WriteBufferSize int
 	Keepalive       bool
 	WriteDeadline   time.Duration
-	ReadDeadline    time.Duration
-	LengthOffset    int
-	ReusePort       bool
-	reuseThread     int
+	// ReadDeadline limits the duration of reading the message after the epoll reports the connection readable,
+	// it is not applied to the connections served by a read loop (tls, wss, quic, udp and WebsocketHandler),
+	// whose read blocks until the next message, use Heartbeat.IdleTimeout to close the idle ones
+	ReadDeadline time.Duration
+	LengthOffset int
+	ReusePort    bool
+	reuseThread  int
+
+	// TLSConfig is required by the tls and wss acceptors,
+	// set ClientAuth and ClientCAs to verify the client certificate
+	TLSConfig *tls.Config
+	// HandshakeTimeout is the max duration of the tls handshake and websocket upgrade
+	HandshakeTimeout time.Duration
+
+	// QUIC is the options of the quic acceptor, which uses TLSConfig as well
+	QUIC QUICOptions
+
+	// UDP is the options of the udp acceptor
+	UDP UDPOptions
+
+	// Websocket is the options of the websocket decoder
+	Websocket codec.WebsocketOptions
+
+	// Handshake is the options of the websocket upgrade
+	Handshake HandshakeOptions
+
+	// Unix is the options of the unix domain socket acceptor
+	Unix UnixOptions
+
+	// Packet is the header layout, used by the quic acceptor to match the responses with the requests,
+	// it is the same as the server's if nil
+	Packet *codec.Options
 }
 
+// UnixOptions represents the options of the unix domain socket acceptor
+type UnixOptions struct {
+	// Mode is the permissions of the socket file, default is 0660
+	Mode os.FileMode
+}
+
+// UDPOptions represents the options of the udp acceptor
+type UDPOptions struct {
+	// IdleTimeout closes the session which has not received any datagram over the duration,
+	// default is one minute, zero means never
+	IdleTimeout time.Duration
+
+	// InboxSize is the count of the datagrams received but not handled per session,
+	// the new datagrams are dropped if it is full, default is 64
+	InboxSize int
+}
+
+// QUICOptions represents the options of the quic acceptor
+type QUICOptions struct {
+	// NextProtos is the ALPN list, used if TLSConfig.NextProtos is empty
+	NextProtos []string
+
+	// Config is the transport config, like MaxIdleTimeout, KeepAlivePeriod and MaxIncomingStreams,
+	// the defaults of quic-go are used if nil
+	Config *quic.Config
+
+	// Allow0RTT accepts the 0-RTT data of resumed connections, be aware that it can be replayed
+	Allow0RTT bool
+
+	// StreamPerRequest answers every request on the stream where it came from,
+	// which allows the clients to send requests in parallel without head-of-line blocking,
+	// the stream of the request which has no response is closed after handled
+	StreamPerRequest bool
+
+	// SelfSigned generates a self-signed certificate if TLSConfig is nil, only for development
+	SelfSigned bool
+}
+
+// Option modifies the options of one acceptor
+type Option func(options *Options)
+
 func DefaultOptions() Options {
 	return Options{
-		Core:            runtime.NumCPU(),
-		ReadBufferSize:  4096,
-		WriteBufferSize: 4096,
-		Keepalive:       false,
-		WriteDeadline:   time.Second * 3,
-		ReadDeadline:    time.Second * 3,
-		LengthOffset:    4,
-		reuseThread:     runtime.NumCPU(),
+		Core:             runtime.NumCPU(),
+		ReadBufferSize:   4096,
+		WriteBufferSize:  4096,
+		Keepalive:        false,
+		WriteDeadline:    time.Second * 3,
+		ReadDeadline:     time.Second * 3,
+		LengthOffset:     4,
+		reuseThread:      runtime.NumCPU(),
+		HandshakeTimeout: time.Second * 5,
+		QUIC: QUICOptions{
+			NextProtos: []string{DefaultQUICProtocol},
+		},
+		UDP: UDPOptions{
+			IdleTimeout: time.Minute,
+			InboxSize:   64,
+		},
+		Unix: UnixOptions{
+			Mode: 0660,
+		},
 	}
 }
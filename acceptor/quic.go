@@ -2,17 +2,25 @@ package acceptor
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
-	"crypto/rsa"
 	"crypto/tls"
 	"crypto/x509"
-	"encoding/pem"
+	"crypto/x509/pkix"
+	"errors"
 	"github.com/ebar-go/ego/utils/runtime"
 	"github.com/ebar-go/znet/codec"
 	"github.com/lucas-clemente/quic-go"
 	"log"
 	"math/big"
 	"net"
+	"time"
+)
+
+const (
+	// DefaultQUICProtocol is the default ALPN of the quic acceptor and client
+	DefaultQUICProtocol = "znet"
 )
 
 type QUICAcceptor struct {
@@ -26,11 +34,16 @@ func (acceptor *QUICAcceptor) ReactorSupported() bool {
 
 // Run runs the acceptor
 func (acceptor *QUICAcceptor) Listen(onAccept func(conn net.Conn)) (err error) {
+	tlsConfig, err := quicTLSConfig(acceptor.options)
+	if err != nil {
+		return
+	}
 
-	lis, err := quic.ListenAddr(acceptor.schema.Addr, generateTLSConfig(), nil)
+	lis, err := listenQUIC(acceptor.schema.Addr, tlsConfig, acceptor.options.QUIC)
 	if err != nil {
 		return
 	}
+	acceptor.AddListener(lis)
 
 	// use multiple cpus to improve performance
 	for i := 0; i < acceptor.options.Core; i++ {
@@ -44,7 +57,7 @@ func (acceptor *QUICAcceptor) Listen(onAccept func(conn net.Conn)) (err error) {
 }
 
 // accept connection
-func (acceptor *QUICAcceptor) accept(lis quic.Listener, onAccept func(conn net.Conn)) {
+func (acceptor *QUICAcceptor) accept(lis quicListener, onAccept func(conn net.Conn)) {
 	for {
 		select {
 		case <-acceptor.done:
@@ -53,49 +66,100 @@ func (acceptor *QUICAcceptor) accept(lis quic.Listener, onAccept func(conn net.C
 			conn, err := lis.Accept(context.Background())
 			if err != nil {
 				// if listener close then return
+				if acceptor.stopped() {
+					return
+				}
 				log.Printf("listener.Accept(\"%s\") error(%v)", lis.Addr().String(), err)
 				continue
 			}
 
-			//go func(conn quic.Connection) {
-			//	cc := codec.NewQUICDecoder(conn)
-			//	for {
-			//		bytes := make([]byte, 512)
-			//		n, err := cc.Read(bytes)
-			//		if err != nil {
-			//			return
-			//		}
-			//		log.Println("receive:", cc.RemoteAddr(), bytes[:n])
-			//		cc.Write(bytes[:n])
-			//	}
-			//}(conn)
-
-			onAccept(codec.NewQUICDecoder(conn))
+			onAccept(codec.NewQUICDecoderWithOptions(conn, codec.QUICOptions{
+				StreamPerRequest: acceptor.options.QUIC.StreamPerRequest,
+				Packet:           acceptor.options.Packet,
+			}))
 		}
 	}
 
 }
 
-// generateTLSConfig set up a bare-bones TLS config for the server
-func generateTLSConfig() *tls.Config {
-	key, err := rsa.GenerateKey(rand.Reader, 1024)
-	if err != nil {
-		panic(err)
+// quicListener is the common part of quic.Listener and quic.EarlyListener
+type quicListener interface {
+	Close() error
+	Addr() net.Addr
+	Accept(ctx context.Context) (quic.Connection, error)
+}
+
+// earlyListener accepts the connections before the handshake completes, which allows 0-RTT
+type earlyListener struct {
+	quic.EarlyListener
+}
+
+func (lis earlyListener) Accept(ctx context.Context) (quic.Connection, error) {
+	return lis.EarlyListener.Accept(ctx)
+}
+
+func listenQUIC(addr string, tlsConfig *tls.Config, options QUICOptions) (quicListener, error) {
+	if !options.Allow0RTT {
+		return quic.ListenAddr(addr, tlsConfig, options.Config)
 	}
-	template := x509.Certificate{SerialNumber: big.NewInt(1)}
-	certDER, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+
+	lis, err := quic.ListenAddrEarly(addr, tlsConfig, options.Config)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
-	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	return earlyListener{lis}, nil
+}
+
+// quicTLSConfig returns the tls config with ALPN of the quic acceptor
+func quicTLSConfig(options Options) (*tls.Config, error) {
+	var config *tls.Config
+	if options.TLSConfig != nil {
+		config = options.TLSConfig.Clone()
+	} else if options.QUIC.SelfSigned {
+		log.Println("[WARN] quic acceptor is using a self-signed certificate, don't use it in production")
+		cert, err := generateCertificate()
+		if err != nil {
+			return nil, err
+		}
+		config = &tls.Config{Certificates: []tls.Certificate{cert}}
+	} else {
+		return nil, ErrTLSConfigRequired
+	}
+
+	if len(config.NextProtos) == 0 {
+		config.NextProtos = options.QUIC.NextProtos
+	}
+	if len(config.NextProtos) == 0 {
+		return nil, errors.New("quic requires at least one application protocol")
+	}
+	return config, nil
+}
 
-	tlsCert, err := tls.X509KeyPair(certPEM, keyPEM)
+// generateCertificate generates a self-signed certificate of localhost for development
+func generateCertificate() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
 	if err != nil {
-		panic(err)
+		return tls.Certificate{}, err
 	}
-	return &tls.Config{
-		Certificates: []tls.Certificate{tlsCert},
-		NextProtos:   []string{"quic-echo-example"},
+
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour * 24 * 365),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
 	}
+	return tls.Certificate{Certificate: [][]byte{certDER}, PrivateKey: key}, nil
 }
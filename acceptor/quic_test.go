@@ -0,0 +1,30 @@
+package acceptor
+
+import (
+	"crypto/tls"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestQuicTLSConfig(t *testing.T) {
+	options := DefaultOptions()
+	_, err := quicTLSConfig(options)
+	assert.Equal(t, ErrTLSConfigRequired, err)
+
+	options.QUIC.SelfSigned = true
+	config, err := quicTLSConfig(options)
+	assert.Nil(t, err)
+	assert.Len(t, config.Certificates, 1)
+	assert.Equal(t, []string{DefaultQUICProtocol}, config.NextProtos)
+
+	options.TLSConfig = &tls.Config{NextProtos: []string{"app/1"}}
+	config, err = quicTLSConfig(options)
+	assert.Nil(t, err)
+	assert.Empty(t, config.Certificates)
+	assert.Equal(t, []string{"app/1"}, config.NextProtos)
+
+	options.TLSConfig = &tls.Config{}
+	options.QUIC.NextProtos = nil
+	_, err = quicTLSConfig(options)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,79 @@
+package acceptor
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// Factory creates the acceptor of the schema, the custom acceptors embed the one returned by NewBaseAcceptor
+type Factory func(schema Schema, options Options) Instance
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]Factory)
+)
+
+func init() {
+	Register(TCP, newTCPAcceptor(false))
+	Register(TLS, newTCPAcceptor(true))
+	Register(WEBSOCKET, newWebsocketAcceptor(false))
+	Register(WSS, newWebsocketAcceptor(true))
+	Register(QUIC, func(schema Schema, options Options) Instance {
+		return &QUICAcceptor{Acceptor: NewBaseAcceptor(schema), options: options}
+	})
+	Register(UNIX, func(schema Schema, options Options) Instance {
+		return &UnixAcceptor{Acceptor: NewBaseAcceptor(schema), options: options}
+	})
+	Register(UDP, func(schema Schema, options Options) Instance {
+		return &UDPAcceptor{Acceptor: NewBaseAcceptor(schema), options: options, sessions: make(map[string]*udpSession)}
+	})
+}
+
+func newTCPAcceptor(secure bool) Factory {
+	return func(schema Schema, options Options) Instance {
+		return &TCPAcceptor{Acceptor: NewBaseAcceptor(schema), options: options, secure: secure}
+	}
+}
+
+func newWebsocketAcceptor(secure bool) Factory {
+	return func(schema Schema, options Options) Instance {
+		return &WebsocketAcceptor{Acceptor: NewBaseAcceptor(schema), options: options, secure: secure}
+	}
+}
+
+// Register makes the acceptor available by the protocol name, it panics if the protocol is registered twice
+func Register(protocol string, factory Factory) {
+	if protocol == "" || factory == nil {
+		panic("acceptor: Register protocol is empty or factory is nil")
+	}
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	if _, exists := factories[protocol]; exists {
+		panic("acceptor: Register called twice for protocol " + protocol)
+	}
+	factories[protocol] = factory
+}
+
+// Protocols returns the sorted names of the registered protocols
+func Protocols() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	protocols := make([]string, 0, len(factories))
+	for protocol := range factories {
+		protocols = append(protocols, protocol)
+	}
+	sort.Strings(protocols)
+	return protocols
+}
+
+// New returns the acceptor of the schema, returns error if the protocol is not registered
+func New(schema Schema, options Options) (Instance, error) {
+	factoriesMu.RLock()
+	factory, ok := factories[schema.Protocol]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, errors.New("unsupported protocol: " + schema.Protocol)
+	}
+	return factory(schema, options), nil
+}
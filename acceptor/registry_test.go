@@ -0,0 +1,56 @@
+package acceptor
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+type customAcceptor struct {
+	*Acceptor
+}
+
+func (acceptor *customAcceptor) Listen(onAccept func(conn net.Conn)) error {
+	return nil
+}
+
+// unregister removes the protocol registered by test, so the test can run again with -count
+func unregister(protocol string) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	delete(factories, protocol)
+}
+
+func TestRegister(t *testing.T) {
+	Register("custom", func(schema Schema, options Options) Instance {
+		return &customAcceptor{Acceptor: NewBaseAcceptor(schema)}
+	})
+	t.Cleanup(func() { unregister("custom") })
+	assert.Contains(t, Protocols(), "custom")
+	assert.Panics(t, func() {
+		Register("custom", func(schema Schema, options Options) Instance { return nil })
+	})
+	assert.Panics(t, func() {
+		Register("", nil)
+	})
+
+	instance, err := New(NewSchema("custom", ":8080"), DefaultOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, "custom://:8080", instance.Schema().String())
+	instance.Shutdown()
+	<-instance.(*customAcceptor).Done()
+}
+
+func TestNew(t *testing.T) {
+	for _, protocol := range []string{TCP, TLS, WEBSOCKET, WSS, QUIC, UDP, UNIX} {
+		instance, err := New(NewSchema(protocol, ":8080"), DefaultOptions())
+		assert.Nil(t, err)
+		assert.NotNil(t, instance)
+	}
+
+	instance, err := New(NewSchema("http", ":8080"), DefaultOptions())
+	assert.Nil(t, instance)
+	assert.Equal(t, errors.New("unsupported protocol: http"), err)
+	assert.Nil(t, NewAcceptor(NewSchema("http", ":8080"), DefaultOptions()))
+}
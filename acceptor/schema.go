@@ -1,13 +1,19 @@
 package acceptor
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 )
 
 const (
 	TCP       = "tcp"
+	TLS       = "tls"
 	WEBSOCKET = "ws"
+	WSS       = "wss"
 	QUIC      = "quic"
+	UDP       = "udp"
+	UNIX      = "unix"
 )
 
 // Schema represents a protocol specification
@@ -21,6 +27,15 @@ func (schema Schema) String() string {
 	return fmt.Sprintf("%s://%s", schema.Protocol, schema.Addr)
 }
 
+// ParseSchema parses the schema string like tcp://:8081 or unix:///tmp/znet.sock
+func ParseSchema(s string) (Schema, error) {
+	protocol, addr, ok := strings.Cut(s, "://")
+	if !ok || protocol == "" || addr == "" {
+		return Schema{}, errors.New("invalid schema: " + s)
+	}
+	return NewSchema(protocol, addr), nil
+}
+
 func NewSchema(protocol string, addr string) Schema {
 	return Schema{
 		Protocol: protocol,
@@ -31,10 +46,26 @@ func NewSchema(protocol string, addr string) Schema {
 func NewTCPSchema(addr string) Schema {
 	return NewSchema(TCP, addr)
 }
+func NewTLSSchema(addr string) Schema {
+	return NewSchema(TLS, addr)
+}
+
 func NewWebSocketSchema(addr string) Schema {
 	return NewSchema(WEBSOCKET, addr)
 }
 
+func NewWSSSchema(addr string) Schema {
+	return NewSchema(WSS, addr)
+}
+
 func NewQUICSchema(addr string) Schema {
 	return NewSchema(QUIC, addr)
 }
+
+func NewUDPSchema(addr string) Schema {
+	return NewSchema(UDP, addr)
+}
+
+func NewUnixSchema(path string) Schema {
+	return NewSchema(UNIX, path)
+}
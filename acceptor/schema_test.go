@@ -23,13 +23,23 @@ func TestSchema_String(t *testing.T) {
 	assert.Equal(t, "tcp://:8081", schema.String())
 }
 
+func TestParseSchema(t *testing.T) {
+	schema, err := ParseSchema("unix:///tmp/znet.sock")
+	assert.Nil(t, err)
+	assert.Equal(t, NewUnixSchema("/tmp/znet.sock"), schema)
+
+	for _, s := range []string{":8081", "://:8081", "tcp://"} {
+		_, err = ParseSchema(s)
+		assert.NotNil(t, err)
+	}
+}
+
 func TestSchema_Listen(t *testing.T) {
 	type fields struct {
 		Protocol string
 		Addr     string
 	}
 	type args struct {
-		stopCh  <-chan struct{}
 		handler func(conn net.Conn)
 	}
 	tests := []struct {
@@ -45,7 +55,6 @@ func TestSchema_Listen(t *testing.T) {
 				Addr:     ":8081",
 			},
 			args: args{
-				stopCh:  make(chan struct{}),
 				handler: func(conn net.Conn) {},
 			},
 			wantErr: nil,
@@ -57,7 +66,6 @@ func TestSchema_Listen(t *testing.T) {
 				Addr:     ":8082",
 			},
 			args: args{
-				stopCh:  make(chan struct{}),
 				handler: func(conn net.Conn) {},
 			},
 			wantErr: nil,
@@ -69,7 +77,6 @@ func TestSchema_Listen(t *testing.T) {
 				Addr:     ":8083",
 			},
 			args: args{
-				stopCh:  make(chan struct{}),
 				handler: func(conn net.Conn) {},
 			},
 			wantErr: errors.New("unsupported protocol: " + "http"),
@@ -81,7 +88,14 @@ func TestSchema_Listen(t *testing.T) {
 				Protocol: tt.fields.Protocol,
 				Addr:     tt.fields.Addr,
 			}
-			assert.Equal(t, tt.wantErr, schema.Listen(tt.args.stopCh, tt.args.handler))
+			// the acceptor of schema is created by the registry
+			instance, err := New(schema, DefaultOptions())
+			if err != nil {
+				assert.Equal(t, tt.wantErr, err)
+				return
+			}
+			assert.Equal(t, tt.wantErr, instance.Listen(tt.args.handler))
+			instance.Shutdown()
 		})
 	}
 }
@@ -15,10 +15,21 @@ import (
 type TCPAcceptor struct {
 	*Acceptor
 	options Options
+	// secure enables tls
+	secure bool
+}
+
+// ReactorSupported returns false if tls is enabled,
+// because the tls records buffered in user space can't be noticed by epoll
+func (acceptor *TCPAcceptor) ReactorSupported() bool {
+	return !acceptor.secure
 }
 
 // Run runs the acceptor
 func (acceptor *TCPAcceptor) Listen(onAccept func(conn net.Conn)) (err error) {
+	if acceptor.secure && acceptor.options.TLSConfig == nil {
+		return ErrTLSConfigRequired
+	}
 	if acceptor.options.ReusePort {
 		return acceptor.listenReuseAddress(onAccept)
 	}
@@ -58,6 +69,7 @@ func (acceptor *TCPAcceptor) listenReuseAddress(onAccept func(conn net.Conn)) (e
 }
 
 func (acceptor *TCPAcceptor) serve(lis *net.TCPListener, onAccept func(conn net.Conn)) {
+	acceptor.AddListener(lis)
 	for i := 0; i < acceptor.options.Core; i++ {
 		go func() {
 			defer runtime.HandleCrash()
@@ -76,6 +88,9 @@ func (acceptor *TCPAcceptor) accept(lis *net.TCPListener, onAccept func(conn net
 			conn, err := lis.AcceptTCP()
 			if err != nil {
 				// if listener close then return
+				if acceptor.stopped() {
+					return
+				}
 				log.Printf("listener.Accept(\"%s\") error(%v)", lis.Addr().String(), err)
 				continue
 			}
@@ -92,8 +107,23 @@ func (acceptor *TCPAcceptor) accept(lis *net.TCPListener, onAccept func(conn net
 				continue
 			}
 
+			if acceptor.secure {
+				go acceptor.handshake(conn, onAccept)
+				continue
+			}
 			onAccept(codec.NewLengthFieldBasedFromDecoder(conn, acceptor.options.LengthOffset))
 		}
 	}
 
 }
+
+// handshake completes the tls handshake without blocking the accept loop
+func (acceptor *TCPAcceptor) handshake(conn net.Conn, onAccept func(conn net.Conn)) {
+	defer runtime.HandleCrash()
+	tlsConn, err := serverTLS(conn, acceptor.options)
+	if err != nil {
+		log.Printf("handshake(\"%s\") error(%v)", conn.RemoteAddr().String(), err)
+		return
+	}
+	onAccept(codec.NewLengthFieldBasedFromDecoder(tlsConn, acceptor.options.LengthOffset))
+}
@@ -0,0 +1,50 @@
+package acceptor
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"os"
+	"time"
+)
+
+var (
+	ErrTLSConfigRequired = errors.New("tls config is required")
+)
+
+// NewTLSConfig loads the server certificate, the client certificate will be verified if clientCAFile is not empty
+func NewTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if clientCAFile == "" {
+		return config, nil
+	}
+
+	pem, err := os.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("invalid client ca file")
+	}
+	config.ClientCAs = pool
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	return config, nil
+}
+
+// serverTLS wraps the connection with tls and completes the handshake
+func serverTLS(conn net.Conn, options Options) (*tls.Conn, error) {
+	tlsConn := tls.Server(conn, options.TLSConfig)
+	_ = conn.SetDeadline(time.Now().Add(options.HandshakeTimeout))
+	if err := tlsConn.Handshake(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return tlsConn, nil
+}
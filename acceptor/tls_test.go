@@ -0,0 +1,19 @@
+package acceptor
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+func TestNewTLSConfig(t *testing.T) {
+	config, err := NewTLSConfig("not-exist.crt", "not-exist.key", "")
+	assert.NotNil(t, err)
+	assert.Nil(t, config)
+}
+
+func TestTCPAcceptor_ListenTLSWithoutConfig(t *testing.T) {
+	acceptor := NewAcceptor(NewTLSSchema(":18090"), DefaultOptions())
+	err := acceptor.Listen(func(conn net.Conn) {})
+	assert.Equal(t, ErrTLSConfigRequired, err)
+}
@@ -0,0 +1,224 @@
+package acceptor
+
+import (
+	"errors"
+	"github.com/ebar-go/ego/utils/runtime"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxDatagramSize is the max size of the udp payload
+const maxDatagramSize = 65535
+
+// ErrDatagramTooLarge is returned by Read if the datagram is larger than the buffer, the datagram is dropped
+// and the session is still readable
+var ErrDatagramTooLarge = errors.New("datagram is too large")
+
+// UDPAcceptor represents udp acceptor, the remote addresses are mapped to virtual sessions,
+// every datagram is a whole packet
+type UDPAcceptor struct {
+	*Acceptor
+	options Options
+
+	mu       sync.Mutex
+	sessions map[string]*udpSession
+}
+
+// ReactorSupported returns false because the sessions share the same socket
+func (acceptor *UDPAcceptor) ReactorSupported() bool {
+	return false
+}
+
+// Listen runs the udp acceptor
+func (acceptor *UDPAcceptor) Listen(onAccept func(conn net.Conn)) (err error) {
+	addr, err := net.ResolveUDPAddr("udp", acceptor.schema.Addr)
+	if err != nil {
+		return
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return
+	}
+	acceptor.AddListener(conn)
+
+	go func() {
+		defer runtime.HandleCrash()
+		acceptor.receive(conn, onAccept)
+	}()
+
+	go func() {
+		defer runtime.HandleCrash()
+		acceptor.expire()
+	}()
+	return
+}
+
+// receive reads the datagrams and dispatches them to the sessions
+func (acceptor *UDPAcceptor) receive(conn *net.UDPConn, onAccept func(conn net.Conn)) {
+	defer acceptor.closeSessions()
+
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, addr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			// if listener close then return
+			if acceptor.stopped() {
+				return
+			}
+			log.Printf("conn.ReadFromUDP(\"%s\") error(%v)", conn.LocalAddr().String(), err)
+			continue
+		}
+
+		datagram := make([]byte, n)
+		copy(datagram, buf[:n])
+
+		session, created := acceptor.session(conn, addr)
+		session.offer(datagram)
+		if created {
+			onAccept(session)
+		}
+	}
+}
+
+// session returns the session of the remote address, creates it if not exists
+func (acceptor *UDPAcceptor) session(conn *net.UDPConn, addr *net.UDPAddr) (*udpSession, bool) {
+	key := addr.String()
+	acceptor.mu.Lock()
+	defer acceptor.mu.Unlock()
+	if session, ok := acceptor.sessions[key]; ok {
+		return session, false
+	}
+
+	session := &udpSession{
+		conn:   conn,
+		remote: addr,
+		inbox:  make(chan []byte, acceptor.options.UDP.InboxSize),
+		done:   make(chan struct{}),
+		onClose: func() {
+			acceptor.mu.Lock()
+			delete(acceptor.sessions, key)
+			acceptor.mu.Unlock()
+		},
+	}
+	acceptor.sessions[key] = session
+	return session, true
+}
+
+// expire closes the sessions which have not received any datagram over the idle timeout
+func (acceptor *UDPAcceptor) expire() {
+	timeout := acceptor.options.UDP.IdleTimeout
+	if timeout <= 0 {
+		return
+	}
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-acceptor.done:
+			return
+		case now := <-ticker.C:
+			for _, session := range acceptor.snapshot() {
+				if now.Sub(session.lastActive()) > timeout {
+					_ = session.Close()
+				}
+			}
+		}
+	}
+}
+
+func (acceptor *UDPAcceptor) closeSessions() {
+	for _, session := range acceptor.snapshot() {
+		_ = session.Close()
+	}
+}
+
+func (acceptor *UDPAcceptor) snapshot() []*udpSession {
+	acceptor.mu.Lock()
+	defer acceptor.mu.Unlock()
+	sessions := make([]*udpSession, 0, len(acceptor.sessions))
+	for _, session := range acceptor.sessions {
+		sessions = append(sessions, session)
+	}
+	return sessions
+}
+
+// udpSession is a virtual connection of the remote address
+type udpSession struct {
+	conn   *net.UDPConn
+	remote *net.UDPAddr
+	// inbox is the datagrams not read, the new datagram is dropped if it is full
+	inbox  chan []byte
+	active int64
+
+	once    sync.Once
+	done    chan struct{}
+	onClose func()
+}
+
+func (session *udpSession) offer(datagram []byte) {
+	atomic.StoreInt64(&session.active, time.Now().UnixNano())
+	select {
+	case session.inbox <- datagram:
+	default:
+	}
+}
+
+func (session *udpSession) lastActive() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&session.active))
+}
+
+// Read reads a whole datagram, the datagram larger than b is dropped with ErrDatagramTooLarge
+func (session *udpSession) Read(b []byte) (n int, err error) {
+	select {
+	case datagram := <-session.inbox:
+		if len(datagram) > len(b) {
+			return 0, ErrDatagramTooLarge
+		}
+		return copy(b, datagram), nil
+	case <-session.done:
+		return 0, io.EOF
+	}
+}
+
+// Write writes b as a datagram
+func (session *udpSession) Write(b []byte) (n int, err error) {
+	select {
+	case <-session.done:
+		return 0, net.ErrClosed
+	default:
+	}
+	return session.conn.WriteToUDP(b, session.remote)
+}
+
+func (session *udpSession) Close() error {
+	session.once.Do(func() {
+		close(session.done)
+		session.onClose()
+	})
+	return nil
+}
+
+func (session *udpSession) LocalAddr() net.Addr {
+	return session.conn.LocalAddr()
+}
+
+func (session *udpSession) RemoteAddr() net.Addr {
+	return session.remote
+}
+
+// SetDeadline is not supported because the socket is shared by the sessions
+func (session *udpSession) SetDeadline(t time.Time) error {
+	return nil
+}
+
+func (session *udpSession) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (session *udpSession) SetWriteDeadline(t time.Time) error {
+	return nil
+}
@@ -0,0 +1,58 @@
+package acceptor
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestUDPAcceptor_Listen(t *testing.T) {
+	options := DefaultOptions()
+	options.UDP.IdleTimeout = time.Millisecond * 200
+	instance := NewAcceptor(NewUDPSchema("127.0.0.1:18095"), options)
+	assert.False(t, instance.ReactorSupported())
+
+	accepted := make(chan net.Conn, 1)
+	err := instance.Listen(func(conn net.Conn) {
+		accepted <- conn
+	})
+	assert.Nil(t, err)
+	defer instance.Shutdown()
+
+	client, err := net.Dial("udp", "127.0.0.1:18095")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	_, err = client.Write([]byte("hello"))
+	assert.Nil(t, err)
+
+	session := <-accepted
+	assert.Equal(t, client.LocalAddr().String(), session.RemoteAddr().String())
+	buf := make([]byte, 64)
+	n, err := session.Read(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", string(buf[:n]))
+
+	// the oversized datagram is dropped, the session keeps reading
+	_, err = client.Write(make([]byte, len(buf)+1))
+	assert.Nil(t, err)
+	_, err = session.Read(buf)
+	assert.Equal(t, ErrDatagramTooLarge, err)
+	_, err = client.Write([]byte("again"))
+	assert.Nil(t, err)
+	n, err = session.Read(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "again", string(buf[:n]))
+
+	_, err = session.Write([]byte("world"))
+	assert.Nil(t, err)
+	n, err = client.Read(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "world", string(buf[:n]))
+
+	// the idle session is expired
+	_, err = session.Read(buf)
+	assert.NotNil(t, err)
+	assert.Len(t, instance.(*UDPAcceptor).snapshot(), 0)
+}
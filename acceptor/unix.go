@@ -0,0 +1,107 @@
+package acceptor
+
+import (
+	"errors"
+	"github.com/ebar-go/ego/utils/runtime"
+	"github.com/ebar-go/znet/codec"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	ErrSocketFileInUse = errors.New("socket file is in use")
+)
+
+// UnixAcceptor represents unix domain socket acceptor, the packets are framed like tcp
+type UnixAcceptor struct {
+	*Acceptor
+	options Options
+}
+
+// Listen runs the unix acceptor
+func (acceptor *UnixAcceptor) Listen(onAccept func(conn net.Conn)) (err error) {
+	path := acceptor.schema.Addr
+	// the abstract socket has no file
+	abstract := strings.HasPrefix(path, "@")
+	if !abstract {
+		if err = removeStaleSocket(path); err != nil {
+			return
+		}
+	}
+
+	lis, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return
+	}
+	if !abstract {
+		if err = os.Chmod(path, acceptor.options.Unix.Mode); err != nil {
+			_ = lis.Close()
+			return
+		}
+	}
+	// the socket file is removed when the listener is closed
+	acceptor.AddListener(lis)
+
+	for i := 0; i < acceptor.options.Core; i++ {
+		go func() {
+			defer runtime.HandleCrash()
+			acceptor.accept(lis, onAccept)
+		}()
+	}
+	return
+}
+
+// accept connection
+func (acceptor *UnixAcceptor) accept(lis *net.UnixListener, onAccept func(conn net.Conn)) {
+	for {
+		select {
+		case <-acceptor.done:
+			return
+		default:
+			conn, err := lis.AcceptUnix()
+			if err != nil {
+				// if listener close then return
+				if acceptor.stopped() {
+					return
+				}
+				log.Printf("listener.Accept(\"%s\") error(%v)", lis.Addr().String(), err)
+				continue
+			}
+			if err = conn.SetReadBuffer(acceptor.options.ReadBufferSize); err != nil {
+				log.Printf("conn.SetReadBuffer() error(%v)", err)
+				continue
+			}
+			if err = conn.SetWriteBuffer(acceptor.options.WriteBufferSize); err != nil {
+				log.Printf("conn.SetWriteBuffer() error(%v)", err)
+				continue
+			}
+
+			onAccept(codec.NewLengthFieldBasedFromDecoder(conn, acceptor.options.LengthOffset))
+		}
+	}
+}
+
+// removeStaleSocket removes the socket file left by the crashed process,
+// returns error if the file is not a socket or another process is listening on it
+func removeStaleSocket(path string) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return errors.New("not a socket file: " + path)
+	}
+
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		_ = conn.Close()
+		return ErrSocketFileInUse
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,43 @@
+package acceptor
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnixAcceptor_Listen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "znet.sock")
+
+	// stale socket file left by the crashed process
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	assert.Nil(t, err)
+	stale.SetUnlinkOnClose(false)
+	_ = stale.Close()
+
+	instance := NewAcceptor(NewUnixSchema(path), DefaultOptions())
+	accepted := make(chan net.Conn, 1)
+	err = instance.Listen(func(conn net.Conn) {
+		accepted <- conn
+	})
+	assert.Nil(t, err)
+
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0660), info.Mode().Perm())
+
+	// another acceptor can't take over the socket file in use
+	err = NewAcceptor(NewUnixSchema(path), DefaultOptions()).Listen(func(conn net.Conn) {})
+	assert.Equal(t, ErrSocketFileInUse, err)
+
+	conn, err := net.Dial("unix", path)
+	assert.Nil(t, err)
+	defer conn.Close()
+	assert.NotNil(t, <-accepted)
+
+	instance.Shutdown()
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -3,24 +3,36 @@ package acceptor
 import (
 	"github.com/ebar-go/ego/utils/runtime"
 	"github.com/ebar-go/znet/codec"
-	"github.com/gobwas/ws"
 	"log"
 	"net"
+	"net/http"
+	"time"
 )
 
 // WebsocketAcceptor represents websocket acceptor
 type WebsocketAcceptor struct {
 	*Acceptor
 	options Options
-	upgrade ws.Upgrader
+	// secure enables tls
+	secure bool
+}
+
+// ReactorSupported returns false if tls is enabled,
+// because the tls records buffered in user space can't be noticed by epoll
+func (acceptor *WebsocketAcceptor) ReactorSupported() bool {
+	return !acceptor.secure
 }
 
 // Run runs websocket acceptor
 func (acceptor *WebsocketAcceptor) Listen(onAccept func(conn net.Conn)) (err error) {
+	if acceptor.secure && acceptor.options.TLSConfig == nil {
+		return ErrTLSConfigRequired
+	}
 	ln, err := net.Listen("tcp", acceptor.schema.Addr)
 	if err != nil {
 		return err
 	}
+	acceptor.AddListener(ln)
 
 	// use multiple cpus to improve performance
 	for i := 0; i < 1; i++ {
@@ -41,17 +53,43 @@ func (acceptor *WebsocketAcceptor) accept(ln net.Listener, onAccept func(conn ne
 		default:
 			conn, err := ln.Accept()
 			if err != nil {
+				if acceptor.stopped() {
+					return
+				}
 				log.Printf("listener.Accept(\"%s\") error(%v)", ln.Addr().String(), err)
 				continue
 			}
 
-			_, err = acceptor.upgrade.Upgrade(conn)
-			if err != nil {
-				log.Printf("upgrade(\"%s\") error(%v)", conn.RemoteAddr().String(), err)
-				continue
-			}
-			onAccept(codec.NewWebsocketDecoder(conn))
+			go acceptor.handshake(conn, onAccept)
 		}
 
 	}
 }
+
+// handshake completes the tls handshake if secure and the upgrade without blocking the accept loop
+func (acceptor *WebsocketAcceptor) handshake(conn net.Conn, onAccept func(conn net.Conn)) {
+	defer runtime.HandleCrash()
+	if acceptor.secure {
+		tlsConn, err := serverTLS(conn, acceptor.options)
+		if err != nil {
+			log.Printf("handshake(\"%s\") error(%v)", conn.RemoteAddr().String(), err)
+			return
+		}
+		conn = tlsConn
+	}
+
+	handshake := &Handshake{Header: http.Header{}}
+	upgrader := newUpgrader(acceptor.options.Handshake, handshake)
+	_ = conn.SetDeadline(time.Now().Add(acceptor.options.HandshakeTimeout))
+	hs, err := upgrader.Upgrade(conn)
+	if err != nil {
+		log.Printf("upgrade(\"%s\") error(%v)", conn.RemoteAddr().String(), err)
+		_ = conn.Close()
+		return
+	}
+	handshake.Protocol = hs.Protocol
+	_ = conn.SetDeadline(time.Time{})
+
+	onAccept(codec.NewWebsocketDecoderWithProperties(conn, acceptor.options.Websocket,
+		handshake.properties(acceptor.options.Handshake)))
+}
@@ -7,39 +7,147 @@ import (
 	"github.com/gobwas/ws"
 	"github.com/lucas-clemente/quic-go"
 	"net"
+	"sync"
 )
 
 type Client struct {
 	net.Conn
+	options Options
+
+	// writeMu make sure the messages are not interleaved
+	writeMu sync.Mutex
+	// calls is the in-flight calls, see Call
+	calls *calls
+}
+
+func newClient(conn net.Conn, setters ...Option) *Client {
+	return &Client{
+		Conn:    conn,
+		options: completeOptions(setters...),
+		calls:   newCalls(),
+	}
+}
+
+// Write writes message to the connection, it is safe for concurrent use
+func (c *Client) Write(p []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.Conn.Write(p)
+}
+
+func DialTCP(addr string, opts ...Option) (*Client, error) {
+	return DialTCPContext(context.Background(), addr, opts...)
+}
+
+// DialTCPContext dials the tcp acceptor, the dialing is aborted when ctx is done
+func DialTCPContext(ctx context.Context, addr string, opts ...Option) (*Client, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return newClient(codec.NewLengthFieldBasedFromDecoder(conn, 4), opts...), nil
+}
+
+// DialTLS dials the tls acceptor with Options.TLSConfig
+func DialTLS(addr string, opts ...Option) (*Client, error) {
+	return DialTLSContext(context.Background(), addr, opts...)
+}
+
+// DialTLSContext dials the tls acceptor with Options.TLSConfig, the dialing is aborted when ctx is done
+func DialTLSContext(ctx context.Context, addr string, opts ...Option) (*Client, error) {
+	options := completeOptions(opts...)
+	dialer := tls.Dialer{Config: options.TLSConfig}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return newClient(codec.NewLengthFieldBasedFromDecoder(conn, 4), opts...), nil
 }
 
-func DialTCP(addr string) (*Client, error) {
-	conn, err := net.Dial("tcp", addr)
+func DialWebSocket(ctx context.Context, addr string, opts ...Option) (*Client, error) {
+	options := completeOptions(opts...)
+	dialer := ws.Dialer{TLSConfig: options.TLSConfig}
+	if options.Header != nil {
+		dialer.Header = ws.HandshakeHeaderHTTP(options.Header)
+	}
+	if options.ContentType != "" {
+		if _, err := codec.New(options.ContentType); err != nil {
+			return nil, err
+		}
+		dialer.Protocols = []string{options.ContentType}
+	}
+	conn, _, hs, err := dialer.Dial(ctx, addr)
 	if err != nil {
 		return nil, err
 	}
+	if hs.Protocol != options.ContentType {
+		_ = conn.Close()
+		return nil, ErrContentTypeRejected
+	}
+
+	return newClient(codec.NewWebsocketClientDecoder(conn), opts...), nil
+}
 
-	return &Client{Conn: codec.NewLengthFieldBasedFromDecoder(conn, 4)}, nil
+// DialQUIC dials the quic acceptor with Options.TLSConfig and Options.QUIC
+func DialQUIC(addr string, opts ...Option) (*Client, error) {
+	return DialQUICContext(context.Background(), addr, opts...)
 }
 
-func DialWebSocket(ctx context.Context, addr string) (*Client, error) {
-	conn, _, _, err := ws.Dial(ctx, addr)
+// DialQUICContext dials the quic acceptor, the dialing is aborted when ctx is done
+func DialQUICContext(ctx context.Context, addr string, opts ...Option) (*Client, error) {
+	options := completeOptions(opts...)
+	tlsConf := &tls.Config{}
+	if options.TLSConfig != nil {
+		tlsConf = options.TLSConfig.Clone()
+	}
+	if len(tlsConf.NextProtos) == 0 {
+		tlsConf.NextProtos = options.QUIC.NextProtos
+	}
+
+	var (
+		conn quic.Connection
+		err  error
+	)
+	if options.QUIC.Enable0RTT {
+		conn, err = quic.DialAddrEarlyContext(ctx, addr, tlsConf, options.QUIC.Config)
+	} else {
+		conn, err = quic.DialAddrContext(ctx, addr, tlsConf, options.QUIC.Config)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	return &Client{Conn: codec.NewWebsocketClientDecoder(conn)}, nil
+	return newClient(codec.NewQUICClientDecoderWithOptions(conn, codec.QUICOptions{
+		StreamPerRequest: options.QUIC.StreamPerRequest,
+		Packet:           options.Packet,
+	}), opts...), nil
 }
 
-func DialQUIC(addr string) (*Client, error) {
-	tlsConf := &tls.Config{
-		InsecureSkipVerify: true,
-		NextProtos:         []string{"quic-echo-example"},
+// DialUnix dials the unix domain socket acceptor
+func DialUnix(path string, opts ...Option) (*Client, error) {
+	return DialUnixContext(context.Background(), path, opts...)
+}
+
+// DialUnixContext dials the unix domain socket acceptor, the dialing is aborted when ctx is done
+func DialUnixContext(ctx context.Context, path string, opts ...Option) (*Client, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", path)
+	if err != nil {
+		return nil, err
 	}
-	conn, err := quic.DialAddr(addr, tlsConf, nil)
+
+	return newClient(codec.NewLengthFieldBasedFromDecoder(conn, 4), opts...), nil
+}
+
+// DialUDP dials the udp acceptor, every packet is sent as a datagram
+func DialUDP(addr string, opts ...Option) (*Client, error) {
+	conn, err := net.Dial("udp", addr)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Client{Conn: codec.NewQUICClientDecoder(conn)}, nil
+	return newClient(conn, opts...), nil
 }
@@ -0,0 +1,157 @@
+package client
+
+import (
+	"crypto/tls"
+	"github.com/ebar-go/znet/acceptor"
+	"github.com/ebar-go/znet/codec"
+	"github.com/lucas-clemente/quic-go"
+	"net/http"
+)
+
+// Options represents the options for the client
+type Options struct {
+	// Codec is used to encode requests and decode responses, default is json
+	Codec codec.Codec
+
+	// ContentType is the name of Codec negotiated with the server, see WithContentType
+	ContentType string
+
+	// MaxReadBufferSize is the size of the max read buffer, default is 4096
+	MaxReadBufferSize int
+
+	// OnPush is called with the packets which are not responses of Call, like server pushes
+	OnPush func(packet *codec.Packet)
+
+	// TLSConfig is used by DialTLS and DialWebSocket with wss:// address,
+	// set Certificates to present the client certificate
+	TLSConfig *tls.Config
+
+	// Header is sent with the websocket upgrade request, like Authorization and Origin
+	Header http.Header
+
+	// Packet is the header layout which must be the same as the server's, default is codec.DefaultOptions()
+	Packet *codec.Options
+
+	// QUIC is the options of DialQUIC, the server certificate is verified with TLSConfig or the system roots
+	QUIC QUICOptions
+}
+
+// QUICOptions represents the options for the quic client
+type QUICOptions struct {
+	// NextProtos is the ALPN list, used if TLSConfig.NextProtos is empty, default is acceptor.DefaultQUICProtocol
+	NextProtos []string
+
+	// Config is the transport config, the defaults of quic-go are used if nil
+	Config *quic.Config
+
+	// Enable0RTT sends data before the handshake completes when the session is resumed,
+	// set TLSConfig.ClientSessionCache to store the sessions
+	Enable0RTT bool
+
+	// StreamPerRequest sends every request on a new stream, which requires the server enables it too,
+	// the request is sent on the control stream once the streams opened reach the limit of server
+	StreamPerRequest bool
+}
+
+type Option func(options *Options)
+
+func completeOptions(setters ...Option) Options {
+	options := defaultOptions()
+	for _, setter := range setters {
+		setter(&options)
+	}
+	return options
+}
+
+func defaultOptions() Options {
+	return Options{
+		Codec:             codec.NewJsonCodec(),
+		MaxReadBufferSize: 4096,
+		Packet:            codec.DefaultOptions(),
+		QUIC: QUICOptions{
+			NextProtos: []string{acceptor.DefaultQUICProtocol},
+		},
+	}
+}
+
+// WithCodec sets the codec
+func WithCodec(cc codec.Codec) Option {
+	return func(options *Options) {
+		options.Codec = cc
+	}
+}
+
+// WithPacketOptions sets the header layout
+func WithPacketOptions(packet *codec.Options) Option {
+	return func(options *Options) {
+		options.Packet = packet
+	}
+}
+
+// WithCompression compresses the body of requests larger than threshold, the layout must be the same as the server's,
+// use it after WithPacketOptions
+func WithCompression(compressor codec.Compressor, threshold int) Option {
+	return func(options *Options) {
+		options.Packet = options.Packet.WithCompression(compressor, threshold)
+	}
+}
+
+// WithContentType uses the codec registered as the content type, which is negotiated with the server
+// through the subprotocol by DialWebSocket, or by Client.Negotiate for other transports
+func WithContentType(contentType string) Option {
+	return func(options *Options) {
+		options.ContentType = contentType
+		if cc, err := codec.New(contentType); err == nil {
+			options.Codec = cc
+		}
+	}
+}
+
+// WithPushHandler sets the handler of the packets pushed by server
+func WithPushHandler(handler func(packet *codec.Packet)) Option {
+	return func(options *Options) {
+		options.OnPush = handler
+	}
+}
+
+// WithTLSConfig sets the tls config
+func WithTLSConfig(config *tls.Config) Option {
+	return func(options *Options) {
+		options.TLSConfig = config
+	}
+}
+
+// WithHeader sets the header of the websocket upgrade request
+func WithHeader(header http.Header) Option {
+	return func(options *Options) {
+		options.Header = header
+	}
+}
+
+// WithQUICConfig sets the quic transport config
+func WithQUICConfig(config *quic.Config) Option {
+	return func(options *Options) {
+		options.QUIC.Config = config
+	}
+}
+
+// WithQUICProtocols sets the ALPN list of the quic client
+func WithQUICProtocols(protos ...string) Option {
+	return func(options *Options) {
+		options.QUIC.NextProtos = protos
+	}
+}
+
+// WithQUIC0RTT enables 0-RTT of the quic client
+func WithQUIC0RTT() Option {
+	return func(options *Options) {
+		options.QUIC.Enable0RTT = true
+	}
+}
+
+// WithQUICStreamPerRequest sends every request on a new stream
+func WithQUICStreamPerRequest() Option {
+	return func(options *Options) {
+		options.QUIC.StreamPerRequest = true
+	}
+}
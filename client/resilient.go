@@ -0,0 +1,318 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotConnected = errors.New("client is not connected")
+)
+
+// State represents the connection state of ResilientClient
+type State int
+
+const (
+	StateDisconnected State = iota
+	StateConnecting
+	StateConnected
+)
+
+func (state State) String() string {
+	switch state {
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	default:
+		return "disconnected"
+	}
+}
+
+// Dialer dials a new client
+type Dialer func(ctx context.Context) (*Client, error)
+
+// TCPDialer returns a dialer of tcp client
+func TCPDialer(addr string, opts ...Option) Dialer {
+	return func(ctx context.Context) (*Client, error) {
+		return DialTCPContext(ctx, addr, opts...)
+	}
+}
+
+// TLSDialer returns a dialer of tls client
+func TLSDialer(addr string, opts ...Option) Dialer {
+	return func(ctx context.Context) (*Client, error) {
+		return DialTLSContext(ctx, addr, opts...)
+	}
+}
+
+// WebSocketDialer returns a dialer of websocket client
+func WebSocketDialer(addr string, opts ...Option) Dialer {
+	return func(ctx context.Context) (*Client, error) {
+		return DialWebSocket(ctx, addr, opts...)
+	}
+}
+
+// QUICDialer returns a dialer of quic client
+func QUICDialer(addr string, opts ...Option) Dialer {
+	return func(ctx context.Context) (*Client, error) {
+		return DialQUICContext(ctx, addr, opts...)
+	}
+}
+
+// UnixDialer returns a dialer of unix domain socket client
+func UnixDialer(path string, opts ...Option) Dialer {
+	return func(ctx context.Context) (*Client, error) {
+		return DialUnixContext(ctx, path, opts...)
+	}
+}
+
+// ResilientOptions represents the options for the ResilientClient
+type ResilientOptions struct {
+	// MinBackoff is the delay of the first reconnecting, default is 500ms
+	MinBackoff time.Duration
+
+	// MaxBackoff is the max delay of reconnecting, default is 30s
+	MaxBackoff time.Duration
+
+	// Jitter is the random fraction of every delay in [0,1], default is 0.2
+	Jitter float64
+
+	// StableDuration is the duration which the connection must stay up to reset the backoff, default is 10s,
+	// so that the connection dropped immediately is not redialed in a busy loop
+	StableDuration time.Duration
+
+	// HeartbeatInterval is the interval of the heartbeat, default is zero which means disabled
+	HeartbeatInterval time.Duration
+
+	// HeartbeatAction is the ping action answered by the server, see znet.HeartbeatOptions,
+	// the heartbeat is disabled if it is zero because the server never answers it
+	HeartbeatAction int32
+
+	// OnStateChange is called when the state changed
+	OnStateChange func(state State)
+
+	// OnConnected is called after every connection established, like login handshake,
+	// the connection will be reestablished if it returns error
+	OnConnected func(ctx context.Context, c *Client) error
+}
+
+type ResilientOption func(options *ResilientOptions)
+
+// WithBackoff sets the min and max delay of reconnecting
+func WithBackoff(min, max time.Duration) ResilientOption {
+	return func(options *ResilientOptions) {
+		options.MinBackoff = min
+		options.MaxBackoff = max
+	}
+}
+
+// WithStableDuration sets the duration which the connection must stay up to reset the backoff
+func WithStableDuration(duration time.Duration) ResilientOption {
+	return func(options *ResilientOptions) {
+		options.StableDuration = duration
+	}
+}
+
+// WithHeartbeat sends the ping action every interval, the connection is reestablished when no pong answered,
+// the action must be the PingAction of server
+func WithHeartbeat(interval time.Duration, action int32) ResilientOption {
+	return func(options *ResilientOptions) {
+		options.HeartbeatInterval = interval
+		options.HeartbeatAction = action
+	}
+}
+
+// WithStateChange sets the callback of state change
+func WithStateChange(callback func(state State)) ResilientOption {
+	return func(options *ResilientOptions) {
+		options.OnStateChange = callback
+	}
+}
+
+// WithHandshake sets the handshake which is called after every connection established
+func WithHandshake(handshake func(ctx context.Context, c *Client) error) ResilientOption {
+	return func(options *ResilientOptions) {
+		options.OnConnected = handshake
+	}
+}
+
+// ResilientClient is a client which reconnects automatically with exponential backoff
+type ResilientClient struct {
+	options ResilientOptions
+	dialer  Dialer
+
+	mu      sync.RWMutex
+	state   State
+	current *Client
+
+	once   sync.Once
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewResilientClient returns a new ResilientClient instance, call Start to connect
+func NewResilientClient(dialer Dialer, setters ...ResilientOption) *ResilientClient {
+	options := ResilientOptions{
+		MinBackoff:     500 * time.Millisecond,
+		MaxBackoff:     30 * time.Second,
+		Jitter:         0.2,
+		StableDuration: 10 * time.Second,
+	}
+	for _, setter := range setters {
+		setter(&options)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ResilientClient{
+		options: options,
+		dialer:  dialer,
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+}
+
+// Start keeps the connection alive in background until closed
+func (rc *ResilientClient) Start() {
+	rc.once.Do(func() {
+		go rc.run()
+	})
+}
+
+// Close stops reconnecting and closes the current connection
+func (rc *ResilientClient) Close() {
+	rc.cancel()
+	rc.once.Do(func() {
+		// never started
+		close(rc.done)
+	})
+	<-rc.done
+}
+
+// State returns the current state
+func (rc *ResilientClient) State() State {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+	return rc.state
+}
+
+// Client returns the current connected client, returns nil if disconnected
+func (rc *ResilientClient) Client() *Client {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+	return rc.current
+}
+
+// Call makes a call over the current connection
+func (rc *ResilientClient) Call(ctx context.Context, action int32, request any, response any) error {
+	c := rc.Client()
+	if c == nil {
+		return ErrNotConnected
+	}
+	return c.Call(ctx, action, request, response)
+}
+
+// ==================private methods================
+func (rc *ResilientClient) run() {
+	defer close(rc.done)
+	defer rc.setState(StateDisconnected, nil)
+
+	for attempt := 0; ; attempt++ {
+		rc.setState(StateConnecting, nil)
+		if c, err := rc.connect(); err != nil {
+			log.Printf("connect failed(attempt=%d): %v", attempt, err)
+		} else {
+			connectedAt := time.Now()
+			rc.setState(StateConnected, c)
+			rc.keepalive(c)
+			_ = c.Close()
+			rc.setState(StateDisconnected, nil)
+
+			// the connection dropped soon is retried with the increasing backoff
+			if time.Since(connectedAt) >= rc.options.StableDuration {
+				attempt = 0
+			}
+		}
+
+		select {
+		case <-rc.ctx.Done():
+			return
+		case <-time.After(rc.backoff(attempt)):
+		}
+	}
+}
+
+// connect dials the connection and runs the handshake
+func (rc *ResilientClient) connect() (*Client, error) {
+	c, err := rc.dialer(rc.ctx)
+	if err != nil {
+		return nil, err
+	}
+	// receive responses for handshake and detect disconnection
+	c.calls.serve(c)
+
+	if rc.options.OnConnected != nil {
+		if err = rc.options.OnConnected(rc.ctx, c); err != nil {
+			_ = c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// keepalive sends heartbeat until the connection is broken or the client is closed
+func (rc *ResilientClient) keepalive(c *Client) {
+	var ticker <-chan time.Time
+	if rc.options.HeartbeatInterval > 0 && rc.options.HeartbeatAction != 0 {
+		t := time.NewTicker(rc.options.HeartbeatInterval)
+		defer t.Stop()
+		ticker = t.C
+	}
+
+	for {
+		select {
+		case <-rc.ctx.Done():
+			return
+		case <-c.calls.done:
+			return
+		case <-ticker:
+			ctx, cancel := context.WithTimeout(rc.ctx, rc.options.HeartbeatInterval)
+			err := c.Call(ctx, rc.options.HeartbeatAction, nil, nil)
+			cancel()
+			if err != nil {
+				log.Printf("heartbeat failed: %v", err)
+				return
+			}
+		}
+	}
+}
+
+// backoff returns the delay of the attempt, increasing exponentially with jitter
+func (rc *ResilientClient) backoff(attempt int) time.Duration {
+	delay := rc.options.MinBackoff
+	for i := 0; i < attempt && delay < rc.options.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > rc.options.MaxBackoff {
+		delay = rc.options.MaxBackoff
+	}
+	return delay - time.Duration(float64(delay)*rc.options.Jitter*rand.Float64())
+}
+
+func (rc *ResilientClient) setState(state State, c *Client) {
+	rc.mu.Lock()
+	changed := rc.state != state
+	rc.state = state
+	rc.current = c
+	rc.mu.Unlock()
+
+	if changed && rc.options.OnStateChange != nil {
+		rc.options.OnStateChange(state)
+	}
+}
@@ -0,0 +1,158 @@
+package client
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+// listen accepts the tcp connections into the channel
+func listen(t *testing.T) (net.Listener, chan net.Conn) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+
+	accepted := make(chan net.Conn, 100)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+	return ln, accepted
+}
+
+func waitState(t *testing.T, states chan State, expected State) {
+	select {
+	case state := <-states:
+		assert.Equal(t, expected, state)
+	case <-time.After(time.Second):
+		t.Fatalf("timeout waiting for state %v", expected)
+	}
+}
+
+func TestResilientClient_Backoff(t *testing.T) {
+	rc := NewResilientClient(nil, WithBackoff(100*time.Millisecond, time.Second))
+	rc.options.Jitter = 0
+	assert.Equal(t, 100*time.Millisecond, rc.backoff(0))
+	assert.Equal(t, 200*time.Millisecond, rc.backoff(1))
+	assert.Equal(t, time.Second, rc.backoff(4))
+	assert.Equal(t, time.Second, rc.backoff(100))
+
+	rc.options.Jitter = 0.5
+	for i := 0; i < 10; i++ {
+		delay := rc.backoff(1)
+		assert.GreaterOrEqual(t, delay, 100*time.Millisecond)
+		assert.LessOrEqual(t, delay, 200*time.Millisecond)
+	}
+}
+
+func TestResilientClient_Reconnect(t *testing.T) {
+	ln, accepted := listen(t)
+
+	states := make(chan State, 100)
+	rc := NewResilientClient(TCPDialer(ln.Addr().String()),
+		WithBackoff(10*time.Millisecond, 100*time.Millisecond),
+		WithStateChange(func(state State) {
+			states <- state
+		}))
+	rc.Start()
+
+	waitState(t, states, StateConnecting)
+	waitState(t, states, StateConnected)
+	assert.Equal(t, StateConnected, rc.State())
+	assert.NotNil(t, rc.Client())
+
+	// the connection is reestablished after the server closed it
+	_ = (<-accepted).Close()
+	waitState(t, states, StateDisconnected)
+	waitState(t, states, StateConnecting)
+	waitState(t, states, StateConnected)
+
+	rc.Close()
+	waitState(t, states, StateDisconnected)
+	assert.Nil(t, rc.Client())
+	assert.Equal(t, ErrNotConnected, rc.Call(context.Background(), 1, nil, nil))
+}
+
+func TestResilientClient_BackoffAfterDropped(t *testing.T) {
+	ln, accepted := listen(t)
+
+	// the server closes every connection immediately
+	go func() {
+		for conn := range accepted {
+			_ = conn.Close()
+		}
+	}()
+
+	dials := 0
+	dialer := TCPDialer(ln.Addr().String())
+	rc := NewResilientClient(func(ctx context.Context) (*Client, error) {
+		dials++
+		return dialer(ctx)
+	}, WithBackoff(20*time.Millisecond, time.Second), WithStableDuration(time.Minute))
+	rc.options.Jitter = 0
+	rc.Start()
+
+	// 20ms + 40ms + 80ms + 160ms, it is not a busy loop
+	time.Sleep(300 * time.Millisecond)
+	rc.Close()
+	assert.LessOrEqual(t, dials, 5)
+	assert.GreaterOrEqual(t, dials, 2)
+}
+
+func TestResilientClient_HeartbeatTimeout(t *testing.T) {
+	ln, accepted := listen(t)
+
+	rc := NewResilientClient(TCPDialer(ln.Addr().String()),
+		WithBackoff(10*time.Millisecond, 100*time.Millisecond),
+		WithHeartbeat(50*time.Millisecond, 1))
+	rc.Start()
+	defer rc.Close()
+
+	// the server never answers the heartbeat, so the client reconnects
+	for i := 0; i < 2; i++ {
+		select {
+		case conn := <-accepted:
+			defer conn.Close()
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for reconnecting")
+		}
+	}
+}
+
+func TestResilientClient_CloseWhileDialing(t *testing.T) {
+	dialing := make(chan struct{})
+	rc := NewResilientClient(func(ctx context.Context) (*Client, error) {
+		close(dialing)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	rc.Start()
+	<-dialing
+
+	closed := make(chan struct{})
+	go func() {
+		rc.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close is blocked by dialing")
+	}
+	assert.Equal(t, StateDisconnected, rc.State())
+}
+
+func TestDialTCPContext(t *testing.T) {
+	ln, _ := listen(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := DialTCPContext(ctx, ln.Addr().String())
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,184 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"github.com/ebar-go/znet/codec"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	ErrClientClosed        = errors.New("client is closed")
+	ErrContentTypeRejected = errors.New("content type is rejected by server")
+	ErrTooManyCalls        = errors.New("too many in-flight calls")
+)
+
+// Call sends the request and waits for the response which has the same seq,
+// it is safe to make many concurrent calls over one client.
+// Once Call is used, the client reads the connection by itself, so don't Read it directly,
+// the packets which are not responses are delivered to Options.OnPush.
+func (c *Client) Call(ctx context.Context, action int32, request any, response any) error {
+	packet, err := c.call(ctx, action, c.options.Codec, request)
+	if err != nil || response == nil {
+		return err
+	}
+	return packet.Unmarshal(response)
+}
+
+// Negotiate asks the server to switch the codec of the connection to Options.ContentType by the handshake packet
+// of the action, which must be the same as the server's. Call it before any other request.
+// The websocket client doesn't need it because the content type is negotiated by the subprotocol.
+func (c *Client) Negotiate(ctx context.Context, action int32) error {
+	if _, err := codec.New(c.options.ContentType); err != nil {
+		return err
+	}
+	// the server replies the content type in use
+	packet, err := c.call(ctx, action, codec.NewRawCodec(), c.options.ContentType)
+	if err != nil {
+		return err
+	}
+	if string(packet.Body) != c.options.ContentType {
+		return ErrContentTypeRejected
+	}
+	return nil
+}
+
+// call sends the request encoded by the codec and waits for the response
+func (c *Client) call(ctx context.Context, action int32, cc codec.Codec, request any) (*codec.Packet, error) {
+	c.calls.serve(c)
+
+	seq, ch, err := c.calls.acquire(c.options.Packet.SeqSize)
+	if err != nil {
+		return nil, err
+	}
+	defer c.calls.release(seq)
+
+	msg, err := codec.NewPacketWithOptions(cc, c.options.Packet).EncodeWith(action, seq, request)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = c.Write(msg); err != nil {
+		return nil, err
+	}
+
+	select {
+	case packet := <-ch:
+		return packet, nil
+	case <-c.calls.done:
+		return nil, c.calls.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// calls manage the in-flight calls, matching the responses by seq
+type calls struct {
+	once    sync.Once
+	seq     int32
+	mu      sync.Mutex
+	pending map[int32]chan *codec.Packet
+
+	// done is closed when the client stops receiving, err is the reason
+	done chan struct{}
+	err  error
+}
+
+func newCalls() *calls {
+	return &calls{
+		pending: make(map[int32]chan *codec.Packet),
+		done:    make(chan struct{}),
+	}
+}
+
+// serve starts receiving the responses only once
+func (calls *calls) serve(c *Client) {
+	calls.once.Do(func() {
+		go calls.receive(c)
+	})
+}
+
+// acquire returns an unused seq which fits the width of header and the channel of response,
+// zero is skipped because it is the seq of server pushes
+func (calls *calls) acquire(size int) (int32, chan *codec.Packet, error) {
+	calls.mu.Lock()
+	defer calls.mu.Unlock()
+
+	select {
+	case <-calls.done:
+		return 0, nil, calls.err
+	default:
+	}
+
+	// every non-zero seq is in use
+	if int64(len(calls.pending)) >= int64(1)<<(8*size)-1 {
+		return 0, nil, ErrTooManyCalls
+	}
+
+	for {
+		seq := wrapSeq(atomic.AddInt32(&calls.seq, 1), size)
+		if _, exist := calls.pending[seq]; exist || seq == 0 {
+			continue
+		}
+		ch := make(chan *codec.Packet, 1)
+		calls.pending[seq] = ch
+		return seq, ch, nil
+	}
+}
+
+func (calls *calls) release(seq int32) {
+	calls.mu.Lock()
+	delete(calls.pending, seq)
+	calls.mu.Unlock()
+}
+
+// receive reads the packets until the connection closed
+func (calls *calls) receive(c *Client) {
+	bytes := make([]byte, c.options.MaxReadBufferSize)
+	for {
+		n, err := c.Conn.Read(bytes)
+		if err != nil {
+			calls.stop(err)
+			return
+		}
+
+		// the body refers to the buffer, so copy the message
+		packet := codec.NewPacketWithOptions(c.options.Codec, c.options.Packet)
+		if err = packet.Unpack(append([]byte(nil), bytes[:n]...)); err != nil {
+			continue
+		}
+
+		calls.mu.Lock()
+		ch, ok := calls.pending[packet.Seq]
+		calls.mu.Unlock()
+		if ok {
+			select {
+			case ch <- packet:
+			default: // duplicated response
+			}
+		} else if c.options.OnPush != nil {
+			c.options.OnPush(packet)
+		}
+	}
+}
+
+func (calls *calls) stop(err error) {
+	calls.mu.Lock()
+	defer calls.mu.Unlock()
+	if err == nil {
+		err = ErrClientClosed
+	}
+	calls.err = err
+	close(calls.done)
+}
+
+// wrapSeq truncates the seq to the width of header, so that it is the same as the seq of response
+func wrapSeq(seq int32, size int) int32 {
+	switch size {
+	case 1:
+		return int32(int8(seq))
+	case 2:
+		return int32(int16(seq))
+	}
+	return seq
+}
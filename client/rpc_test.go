@@ -0,0 +1,143 @@
+package client
+
+import (
+	"context"
+	"github.com/ebar-go/znet/codec"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// pipe returns the client and the server side of an in-memory connection
+func pipe(opts ...Option) (*Client, net.Conn) {
+	server, client := net.Pipe()
+	return newClient(codec.NewLengthFieldBasedFromDecoder(client, 4), opts...),
+		codec.NewLengthFieldBasedFromDecoder(server, 4)
+}
+
+// readRequests reads n requests from the server side connection
+func readRequests(t *testing.T, server net.Conn, n int) []*codec.Packet {
+	packets := make([]*codec.Packet, 0, n)
+	for i := 0; i < n; i++ {
+		p := make([]byte, 512)
+		size, err := server.Read(p)
+		assert.Nil(t, err)
+		packet := codec.NewPacket(codec.NewJsonCodec())
+		assert.Nil(t, packet.Unpack(p[:size]))
+		packets = append(packets, packet)
+	}
+	return packets
+}
+
+func reply(t *testing.T, server net.Conn, action, seq int32, response any) {
+	msg, err := codec.NewPacket(codec.NewJsonCodec()).EncodeWith(action, seq, response)
+	assert.Nil(t, err)
+	_, err = server.Write(msg)
+	assert.Nil(t, err)
+}
+
+func TestClient_Call(t *testing.T) {
+	c, server := pipe()
+	defer c.Close()
+
+	const n = 10
+	go func() {
+		// the responses are sent in reverse order, matched by seq
+		packets := readRequests(t, server, n)
+		for i := len(packets) - 1; i >= 0; i-- {
+			var request int
+			assert.Nil(t, packets[i].Unmarshal(&request))
+			reply(t, server, packets[i].Action, packets[i].Seq, request*2)
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 1; i <= n; i++ {
+		wg.Add(1)
+		go func(request int) {
+			defer wg.Done()
+			var response int
+			assert.Nil(t, c.Call(context.Background(), 1, request, &response))
+			assert.Equal(t, request*2, response)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestClient_CallWithPush(t *testing.T) {
+	pushed := make(chan *codec.Packet, 1)
+	c, server := pipe(WithPushHandler(func(packet *codec.Packet) {
+		pushed <- packet
+	}))
+	defer c.Close()
+
+	go func() {
+		request := readRequests(t, server, 1)[0]
+		// the push is not taken as the response
+		reply(t, server, 2, 0, "push")
+		reply(t, server, request.Action, request.Seq, "pong")
+	}()
+
+	var response string
+	assert.Nil(t, c.Call(context.Background(), 1, "ping", &response))
+	assert.Equal(t, "pong", response)
+
+	packet := <-pushed
+	assert.Equal(t, int32(2), packet.Action)
+	var body string
+	assert.Nil(t, packet.Unmarshal(&body))
+	assert.Equal(t, "push", body)
+}
+
+func TestClient_CallCanceled(t *testing.T) {
+	c, server := pipe()
+	defer c.Close()
+
+	// the server never replies
+	go readRequests(t, server, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, c.Call(ctx, 1, "ping", nil))
+	assert.Empty(t, c.calls.pending)
+}
+
+func TestClient_CallClosed(t *testing.T) {
+	c, server := pipe()
+	go readRequests(t, server, 1)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.Call(context.Background(), 1, "ping", nil)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	_ = server.Close()
+	assert.NotNil(t, <-errCh)
+}
+
+func TestCalls_Acquire(t *testing.T) {
+	calls := newCalls()
+
+	// zero is skipped when the seq wraps
+	calls.seq = 255
+	seq, _, err := calls.acquire(1)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(1), seq)
+
+	// every seq of one byte is in use
+	for i := 1; i < 255; i++ {
+		_, _, err = calls.acquire(1)
+		assert.Nil(t, err)
+	}
+	assert.Len(t, calls.pending, 255)
+	assert.NotContains(t, calls.pending, int32(0))
+	_, _, err = calls.acquire(1)
+	assert.Equal(t, ErrTooManyCalls, err)
+
+	calls.release(seq)
+	seq, _, err = calls.acquire(1)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(1), seq)
+}
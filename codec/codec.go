@@ -3,11 +3,14 @@ package codec
 import (
 	"encoding/json"
 	"errors"
+	ugorji "github.com/ugorji/go/codec"
 	"google.golang.org/protobuf/proto"
+	"reflect"
 )
 
 var (
 	ErrInvalidProtoMessage = errors.New("invalid proto message")
+	ErrInvalidRawData      = errors.New("raw codec requires []byte, string or *[]byte")
 )
 
 type Codec interface {
@@ -52,3 +55,74 @@ func (codec *ProtoCodec) Marshal(data any) ([]byte, error) {
 	}
 	return proto.Marshal(message)
 }
+
+// MsgpackCodec encodes the data with MessagePack, the maps are decoded as map[string]any
+type MsgpackCodec struct {
+	handle *ugorji.MsgpackHandle
+}
+
+func NewMsgpackCodec() *MsgpackCodec {
+	handle := &ugorji.MsgpackHandle{WriteExt: true}
+	handle.RawToString = true
+	handle.MapType = reflect.TypeOf(map[string]any(nil))
+	return &MsgpackCodec{handle: handle}
+}
+
+func (codec *MsgpackCodec) Unmarshal(p []byte, data any) error {
+	return ugorji.NewDecoderBytes(p, codec.handle).Decode(data)
+}
+
+func (codec *MsgpackCodec) Marshal(data any) (p []byte, err error) {
+	err = ugorji.NewEncoderBytes(&p, codec.handle).Encode(data)
+	return
+}
+
+// CBORCodec encodes the data with CBOR, the maps are decoded as map[string]any
+type CBORCodec struct {
+	handle *ugorji.CborHandle
+}
+
+func NewCBORCodec() *CBORCodec {
+	handle := &ugorji.CborHandle{}
+	handle.MapType = reflect.TypeOf(map[string]any(nil))
+	return &CBORCodec{handle: handle}
+}
+
+func (codec *CBORCodec) Unmarshal(p []byte, data any) error {
+	return ugorji.NewDecoderBytes(p, codec.handle).Decode(data)
+}
+
+func (codec *CBORCodec) Marshal(data any) (p []byte, err error) {
+	err = ugorji.NewEncoderBytes(&p, codec.handle).Encode(data)
+	return
+}
+
+// RawCodec passes the body through untouched, the data must be []byte or string, and *[]byte for Unmarshal
+type RawCodec struct {
+}
+
+func NewRawCodec() *RawCodec {
+	return &RawCodec{}
+}
+
+func (codec *RawCodec) Unmarshal(p []byte, data any) error {
+	container, ok := data.(*[]byte)
+	if !ok {
+		return ErrInvalidRawData
+	}
+	// the body may refer to the read buffer which is recycled
+	*container = append((*container)[:0], p...)
+	return nil
+}
+
+func (codec *RawCodec) Marshal(data any) ([]byte, error) {
+	switch v := data.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	}
+	return nil, ErrInvalidRawData
+}
@@ -0,0 +1,228 @@
+package codec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"sync"
+)
+
+const (
+	// FlagsField is the name of the extra field which carries the compressor id of the body in the low 3 bits
+	FlagsField = "flags"
+
+	// compressorMask is the bits of flags for the compressor id, zero means uncompressed
+	compressorMask = 0x07
+
+	// maxDecompressedSize limits the size of the decompressed body
+	maxDecompressedSize = 16 << 20
+)
+
+const (
+	GzipCompressorID byte = iota + 1
+	SnappyCompressorID
+	ZstdCompressorID
+)
+
+var (
+	ErrUnknownCompressor = errors.New("unknown compressor")
+	ErrBodyTooLarge      = errors.New("decompressed body is too large")
+)
+
+// Compressor compresses the packet body
+type Compressor interface {
+	// ID identifies the compressor in the flags field, must be 1 ~ 7, 1 ~ 3 are used by the built-in compressors
+	ID() byte
+	Compress(p []byte) ([]byte, error)
+	Decompress(p []byte) ([]byte, error)
+}
+
+var (
+	compressorsMu sync.RWMutex
+	compressors   = make(map[byte]Compressor)
+)
+
+func init() {
+	RegisterCompressor(NewGzipCompressor())
+	RegisterCompressor(NewSnappyCompressor())
+	RegisterCompressor(NewZstdCompressor())
+}
+
+// RegisterCompressor makes the compressor available for decompressing by its id,
+// it panics if the id is invalid or registered twice
+func RegisterCompressor(compressor Compressor) {
+	id := compressor.ID()
+	if id == 0 || id > compressorMask {
+		panic(fmt.Sprintf("codec: invalid compressor id %d", id))
+	}
+	compressorsMu.Lock()
+	defer compressorsMu.Unlock()
+	if _, exists := compressors[id]; exists {
+		panic(fmt.Sprintf("codec: RegisterCompressor called twice for id %d", id))
+	}
+	compressors[id] = compressor
+}
+
+func lookupCompressor(id byte) (Compressor, bool) {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	compressor, ok := compressors[id]
+	return compressor, ok
+}
+
+// WithCompression returns a copy of options which compresses the body larger than threshold,
+// the flags field is appended to the header if it is not defined
+func (options *Options) WithCompression(compressor Compressor, threshold int) *Options {
+	copied := *options
+	copied.Fields = append([]Field(nil), options.Fields...)
+	if offset, _ := copied.field(FlagsField); offset < 0 {
+		copied.Fields = append(copied.Fields, Field{Name: FlagsField, Size: 1})
+	}
+	copied.Compressor = compressor
+	copied.CompressThreshold = threshold
+	return &copied
+}
+
+// compress returns the compressed body and the compressor id, the body is not compressed if it is small
+// or the compressed one is not smaller
+func (options *Options) compress(body []byte) ([]byte, byte, error) {
+	if options.Compressor == nil || len(body) <= options.CompressThreshold {
+		return body, 0, nil
+	}
+	compressed, err := options.Compressor.Compress(body)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(compressed) >= len(body) {
+		return body, 0, nil
+	}
+	return compressed, options.Compressor.ID(), nil
+}
+
+// decompress returns the body decompressed by the compressor of id
+func decompress(id byte, body []byte) ([]byte, error) {
+	if id == 0 {
+		return body, nil
+	}
+	compressor, ok := lookupCompressor(id)
+	if !ok {
+		return nil, ErrUnknownCompressor
+	}
+	return compressor.Decompress(body)
+}
+
+// readLimited reads all data of r no more than maxDecompressedSize
+func readLimited(r io.Reader) ([]byte, error) {
+	p, err := io.ReadAll(io.LimitReader(r, maxDecompressedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(p) > maxDecompressedSize {
+		return nil, ErrBodyTooLarge
+	}
+	return p, nil
+}
+
+type gzipCompressor struct{}
+
+// NewGzipCompressor returns the gzip compressor
+func NewGzipCompressor() Compressor {
+	return gzipCompressor{}
+}
+
+func (gzipCompressor) ID() byte {
+	return GzipCompressorID
+}
+
+func (gzipCompressor) Compress(p []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(p); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCompressor) Decompress(p []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(p))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return readLimited(reader)
+}
+
+type snappyCompressor struct{}
+
+// NewSnappyCompressor returns the snappy compressor, which is fast with lower ratio
+func NewSnappyCompressor() Compressor {
+	return snappyCompressor{}
+}
+
+func (snappyCompressor) ID() byte {
+	return SnappyCompressorID
+}
+
+func (snappyCompressor) Compress(p []byte) ([]byte, error) {
+	return snappy.Encode(nil, p), nil
+}
+
+func (snappyCompressor) Decompress(p []byte) ([]byte, error) {
+	n, err := snappy.DecodedLen(p)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxDecompressedSize {
+		return nil, ErrBodyTooLarge
+	}
+	return snappy.Decode(nil, p)
+}
+
+// zstdCompressor shares the encoder and decoder, which are safe for concurrent use
+type zstdCompressor struct {
+	once    sync.Once
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+	err     error
+}
+
+// NewZstdCompressor returns the zstd compressor
+func NewZstdCompressor() Compressor {
+	return &zstdCompressor{}
+}
+
+func (compressor *zstdCompressor) init() error {
+	compressor.once.Do(func() {
+		compressor.encoder, compressor.err = zstd.NewWriter(nil)
+		if compressor.err != nil {
+			return
+		}
+		compressor.decoder, compressor.err = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxDecompressedSize))
+	})
+	return compressor.err
+}
+
+func (compressor *zstdCompressor) ID() byte {
+	return ZstdCompressorID
+}
+
+func (compressor *zstdCompressor) Compress(p []byte) ([]byte, error) {
+	if err := compressor.init(); err != nil {
+		return nil, err
+	}
+	return compressor.encoder.EncodeAll(p, nil), nil
+}
+
+func (compressor *zstdCompressor) Decompress(p []byte) ([]byte, error) {
+	if err := compressor.init(); err != nil {
+		return nil, err
+	}
+	return compressor.decoder.DecodeAll(p, nil)
+}
@@ -1,176 +1,116 @@
 package codec
 
 import (
-	"context"
 	"errors"
 	"github.com/ebar-go/ego/utils/binary"
 	"github.com/ebar-go/ego/utils/pool"
-	"github.com/gobwas/ws/wsutil"
-	"github.com/lucas-clemente/quic-go"
 	"io"
 	"net"
 	"syscall"
-	"time"
 )
 
+var (
+	ErrSyscallConnUnsupported = errors.New("syscall conn is unsupported")
+	ErrInvalidLength          = errors.New("invalid length")
+)
+
+// syscallConn returns the raw connection for epoll, the tls connection is unsupported
+func syscallConn(conn net.Conn) (syscall.RawConn, error) {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return nil, ErrSyscallConnUnsupported
+	}
+	return sc.SyscallConn()
+}
+
+// Finisher is implemented by the decoders which keep the state of a request until the response is written,
+// Finish is called with the packet of request after it is handled without response, to release the state
+type Finisher interface {
+	Finish(header []byte)
+}
+
 type LengthFieldBasedFrameDecoder struct {
 	net.Conn
-	offset int
-	endian binary.Endian
+	offset  int
+	endian  binary.Endian
+	inbound *inbound
 }
 
 func NewLengthFieldBasedFromDecoder(conn net.Conn, offset int) net.Conn {
 	return &LengthFieldBasedFrameDecoder{
-		Conn:   conn,
-		offset: offset,
-		endian: defaultEndian,
+		Conn:    conn,
+		offset:  offset,
+		endian:  defaultEndian,
+		inbound: newInbound(conn),
 	}
 }
 
 // SyscallConn prepare for epoll
 func (c *LengthFieldBasedFrameDecoder) SyscallConn() (syscall.RawConn, error) {
-	return c.Conn.(syscall.Conn).SyscallConn()
+	return syscallConn(c.Conn)
+}
+
+// NetConn returns the underlying connection
+func (c *LengthFieldBasedFrameDecoder) NetConn() net.Conn {
+	return c.Conn
 }
 func (decoder *LengthFieldBasedFrameDecoder) Read(bytes []byte) (n int, err error) {
-	// read length field of packet
-	p := pool.GetByte(decoder.offset)
-	defer pool.PutByte(p)
-	_, err = io.ReadFull(decoder.Conn, p)
+	length, err := readLengthField(decoder.inbound, decoder.offset, decoder.endian)
 	if err != nil {
 		return
 	}
 
-	length := int(decoder.endian.Int32(p))
 	if length <= decoder.offset || length > len(bytes) {
-		err = errors.New("invalid length")
+		err = ErrInvalidLength
 		return
 	}
-	n, err = io.ReadFull(decoder.Conn, bytes[:length-decoder.offset])
+	n, err = io.ReadFull(decoder.inbound, bytes[:length-decoder.offset])
 	return
 }
 
-func (decoder *LengthFieldBasedFrameDecoder) Write(buf []byte) (n int, err error) {
-	length := decoder.offset + len(buf)
-	p := pool.GetByte(length)
-	defer pool.PutByte(p)
-	decoder.endian.PutInt32(p[:decoder.offset], int32(length))
-	copy(p[decoder.offset:], buf)
-	return decoder.Conn.Write(p)
-}
-
-type websocketDecoder struct {
-	net.Conn
-	isClient bool
+// Drain implements Drainer
+func (decoder *LengthFieldBasedFrameDecoder) Drain(maxSize int, handle func() error) error {
+	return decoder.inbound.drain(func(p []byte) (int, bool, error) {
+		return decoder.complete(p, maxSize)
+	}, handle)
 }
 
-func NewWebsocketDecoder(conn net.Conn) net.Conn {
-	return &websocketDecoder{Conn: conn}
-}
-
-func NewWebsocketClientDecoder(conn net.Conn) net.Conn {
-	return &websocketDecoder{Conn: conn, isClient: true}
-}
-
-// SyscallConn prepare for epoll
-func (c *websocketDecoder) SyscallConn() (syscall.RawConn, error) {
-	return c.Conn.(syscall.Conn).SyscallConn()
-}
-
-func (c *websocketDecoder) Read(p []byte) (n int, err error) {
-	var buf []byte
-	if c.isClient {
-		buf, err = wsutil.ReadServerBinary(c.Conn)
-	} else {
-		buf, err = wsutil.ReadClientBinary(c.Conn)
-	}
-
-	if err != nil {
-		return
+// complete returns the size of the frame at the head of p, the frame with too small length is complete
+// so that Read returns ErrInvalidLength, the frame larger than maxSize is rejected as soon as the length field arrives
+func (decoder *LengthFieldBasedFrameDecoder) complete(p []byte, maxSize int) (int, bool, error) {
+	if len(p) < decoder.offset {
+		return decoder.offset, false, nil
 	}
-	n = copy(p, buf)
-	return
-}
-
-func (c *websocketDecoder) Write(p []byte) (n int, err error) {
-	if c.isClient {
-		err = wsutil.WriteClientBinary(c.Conn, p)
-	} else {
-		err = wsutil.WriteServerBinary(c.Conn, p)
+	length := int(decoder.endian.Int32(p[:decoder.offset]))
+	if length <= decoder.offset {
+		return length, true, nil
 	}
-
-	if err != nil {
-		return
+	if length > maxSize {
+		return 0, false, ErrInvalidLength
 	}
-	n = len(p)
-	return
-}
-
-type quicDecoder struct {
-	conn     quic.Connection
-	isClient bool
+	return length, len(p) >= length, nil
 }
 
-func (decoder *quicDecoder) Read(b []byte) (n int, err error) {
-	var stream quic.Stream
-	if decoder.isClient {
-		stream, err = decoder.conn.OpenStreamSync(context.Background())
-	} else {
-		stream, err = decoder.conn.AcceptStream(context.Background())
-	}
-	if err != nil {
-		return
-	}
-
-	return stream.Read(b)
+func (decoder *LengthFieldBasedFrameDecoder) Write(buf []byte) (n int, err error) {
+	return writeFrame(decoder.Conn, decoder.offset, decoder.endian, buf)
 }
 
-func (decoder *quicDecoder) Write(b []byte) (n int, err error) {
-	var stream quic.Stream
-	if decoder.isClient {
-		stream, err = decoder.conn.OpenStreamSync(context.Background())
-	} else {
-		stream, err = decoder.conn.AcceptStream(context.Background())
-	}
-
-	if err != nil {
+// readLengthField reads the length field of the frame, which includes the length field itself
+func readLengthField(r io.Reader, offset int, endian binary.Endian) (length int, err error) {
+	p := pool.GetByte(offset)
+	defer pool.PutByte(p)
+	if _, err = io.ReadFull(r, p); err != nil {
 		return
 	}
-	return stream.Write(b)
-}
-
-func (decoder *quicDecoder) Close() error {
-	return decoder.conn.CloseWithError(0, "")
-}
-
-func (decoder *quicDecoder) LocalAddr() net.Addr {
-	return decoder.conn.LocalAddr()
-}
-
-func (decoder *quicDecoder) RemoteAddr() net.Addr {
-	return decoder.conn.RemoteAddr()
-}
-
-func (decoder *quicDecoder) SetDeadline(t time.Time) error {
-	return nil
-}
-
-func (decoder *quicDecoder) SetReadDeadline(t time.Time) error {
-	return nil
-}
-
-func (decoder *quicDecoder) SetWriteDeadline(t time.Time) error {
-	return nil
-}
-
-// SyscallConn prepare for epoll
-func (decoder *quicDecoder) SyscallConn() (syscall.RawConn, error) {
-	return nil, nil
+	return int(endian.Int32(p)), nil
 }
 
-func NewQUICDecoder(conn quic.Connection) net.Conn {
-	return &quicDecoder{conn: conn}
-}
-
-func NewQUICClientDecoder(conn quic.Connection) net.Conn {
-	return &quicDecoder{conn: conn, isClient: true}
+// writeFrame writes buf with the length field prefixed
+func writeFrame(w io.Writer, offset int, endian binary.Endian, buf []byte) (n int, err error) {
+	length := offset + len(buf)
+	p := pool.GetByte(length)
+	defer pool.PutByte(p)
+	endian.PutInt32(p[:offset], int32(length))
+	copy(p[offset:], buf)
+	return w.Write(p)
 }
@@ -0,0 +1,116 @@
+package codec
+
+import (
+	"io"
+	"net"
+)
+
+const (
+	// inboundInitSize is the initial size of the inbound buffer, the larger buffer is released after drained
+	inboundInitSize = 4096
+)
+
+// Drainer is implemented by the decoders of the connections managed by edge-triggered epoll
+type Drainer interface {
+	// Drain reads the socket into the inbound buffer until EAGAIN, then calls handle once per complete frame,
+	// in which the frame is read by Read without blocking. The partial frame is kept for the next call.
+	// maxSize is the size of the buffer passed to Read, the frame larger than it is rejected before buffered
+	Drain(maxSize int, handle func() error) error
+}
+
+// inbound buffers the data drained from the socket, the decoder reads it before reading the socket,
+// the buffer is allocated at the first drain, so the connections not managed by epoll read the socket directly
+type inbound struct {
+	conn net.Conn
+	buf  []byte
+	// r and w are the read and write positions of buf
+	r, w int
+}
+
+func newInbound(conn net.Conn) *inbound {
+	return &inbound{conn: conn}
+}
+
+// Read reads the buffered data first, then the socket
+func (in *inbound) Read(p []byte) (int, error) {
+	if in.r < in.w {
+		n := copy(p, in.buf[in.r:in.w])
+		in.r += n
+		return n, nil
+	}
+	return in.conn.Read(p)
+}
+
+// buffered returns the data not read
+func (in *inbound) buffered() []byte {
+	return in.buf[in.r:in.w]
+}
+
+// drain calls handle while complete returns true for the buffered data, until the socket has no more data.
+// complete returns the size of the frame at the head of buffered data, and false if the frame is partial,
+// the error of complete means the frame is invalid, like larger than maxSize
+func (in *inbound) drain(complete func(p []byte) (int, bool, error), handle func() error) error {
+	for {
+		eagain, err := in.fill()
+		for in.r < in.w {
+			size, ok, completeErr := complete(in.buffered())
+			if completeErr != nil {
+				return completeErr
+			}
+			if !ok {
+				in.reserve(size)
+				break
+			}
+			if handleErr := handle(); handleErr != nil {
+				return handleErr
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if eagain {
+			in.shrink()
+			return nil
+		}
+	}
+}
+
+// reserve makes sure the buffer is able to hold the frame of size
+func (in *inbound) reserve(size int) {
+	if size <= len(in.buf) {
+		return
+	}
+	buf := make([]byte, size)
+	in.w = copy(buf, in.buf[in.r:in.w])
+	in.r = 0
+	in.buf = buf
+}
+
+// shrink releases the buffer larger than inboundInitSize if all the data is read,
+// so the connection holds the large buffer only while the large frame is partial
+func (in *inbound) shrink() {
+	if in.r == in.w && len(in.buf) > inboundInitSize {
+		in.buf, in.r, in.w = nil, 0, 0
+	}
+}
+
+// prepare moves the buffered data to the head, returns false if the buffer is full.
+// The buffer is grown by reserve only, for the partial frame which is not larger than the limit of decoder
+func (in *inbound) prepare() bool {
+	if in.buf == nil {
+		in.buf = make([]byte, inboundInitSize)
+	}
+	if in.r > 0 {
+		in.w = copy(in.buf, in.buf[in.r:in.w])
+		in.r = 0
+	}
+	return in.w < len(in.buf)
+}
+
+// eof converts the zero read to io.EOF
+func eof(n int, err error) error {
+	if n == 0 && err == nil {
+		return io.EOF
+	}
+	return err
+}
@@ -0,0 +1,206 @@
+package codec
+
+import (
+	"bytes"
+	"github.com/gobwas/ws"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+// tcpPair returns the server and client side connections of tcp, the inbound buffer reads the socket by syscall
+func tcpPair(t *testing.T) (server, client net.Conn) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer ln.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+	client, err = net.Dial("tcp", ln.Addr().String())
+	assert.Nil(t, err)
+	server = <-accepted
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+	})
+	return
+}
+
+// lengthFrame returns the frame with 4 bytes length field
+func lengthFrame(body string) []byte {
+	p := make([]byte, 4+len(body))
+	defaultEndian.PutInt32(p, int32(len(p)))
+	copy(p[4:], body)
+	return p
+}
+
+// drainFrames drains the decoder until count frames are read, the data written by peer may arrive later
+func drainFrames(t *testing.T, decoder net.Conn, maxSize, count int) (frames []string) {
+	drainer := decoder.(Drainer)
+	assert.Eventually(t, func() bool {
+		err := drainer.Drain(maxSize, func() error {
+			p := make([]byte, maxSize)
+			n, err := decoder.Read(p)
+			frames = append(frames, string(p[:n]))
+			return err
+		})
+		assert.Nil(t, err)
+		return len(frames) >= count
+	}, time.Second, time.Millisecond)
+	return
+}
+
+func TestLengthFieldBasedFrameDecoder_Complete(t *testing.T) {
+	decoder := NewLengthFieldBasedFromDecoder(nil, 4).(*LengthFieldBasedFrameDecoder)
+	frame := lengthFrame("hello")
+
+	// the length field is partial
+	size, ok, err := decoder.complete(frame[:2], 512)
+	assert.Equal(t, 4, size)
+	assert.False(t, ok)
+	assert.Nil(t, err)
+
+	// the body is partial
+	size, ok, err = decoder.complete(frame[:6], 512)
+	assert.Equal(t, len(frame), size)
+	assert.False(t, ok)
+	assert.Nil(t, err)
+
+	size, ok, err = decoder.complete(append(frame, lengthFrame("next")...), 512)
+	assert.Equal(t, len(frame), size)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+
+	// the too small length is left to Read
+	_, ok, err = decoder.complete([]byte{0, 0, 0, 2}, 512)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+
+	// the frame larger than maxSize is rejected without waiting for the body
+	_, _, err = decoder.complete(frame[:4], len(frame)-1)
+	assert.Equal(t, ErrInvalidLength, err)
+}
+
+func TestWebsocketDecoder_Complete(t *testing.T) {
+	decoder := newWebsocketDecoder(nil, false, WebsocketOptions{})
+	frame := func(f ws.Frame) []byte {
+		buf := bytes.Buffer{}
+		assert.Nil(t, ws.WriteFrame(&buf, ws.MaskFrameInPlace(f)))
+		return buf.Bytes()
+	}
+
+	// the header is partial
+	message := frame(ws.NewBinaryFrame([]byte("hello")))
+	size, ok, err := decoder.complete(message[:1], 512)
+	assert.Equal(t, 2, size)
+	assert.False(t, ok)
+	assert.Nil(t, err)
+
+	size, ok, err = decoder.complete(message, 512)
+	assert.Equal(t, len(message), size)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+
+	// the fragmented message is complete with the interleaved control frames
+	fragments := frame(ws.NewFrame(ws.OpBinary, false, []byte("hel")))
+	fragments = append(fragments, frame(ws.NewPingFrame([]byte("ping")))...)
+	fragments = append(fragments, frame(ws.NewFrame(ws.OpContinuation, true, []byte("lo")))...)
+	_, ok, err = decoder.complete(fragments[:len(fragments)-1], 512)
+	assert.False(t, ok)
+	assert.Nil(t, err)
+	size, ok, err = decoder.complete(fragments, 512)
+	assert.Equal(t, len(fragments), size)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+
+	// the control frame at the head is complete by itself
+	ping := frame(ws.NewPingFrame(nil))
+	size, ok, err = decoder.complete(append(ping, fragments...), 512)
+	assert.Equal(t, len(ping), size)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+
+	// the payload of fragments larger than maxSize is rejected by the header
+	_, _, err = decoder.complete(fragments, 4)
+	assert.Equal(t, ErrMessageTooLarge, err)
+	large := frame(ws.NewBinaryFrame(make([]byte, 1024)))
+	_, _, err = decoder.complete(large[:8], 512)
+	assert.Equal(t, ErrMessageTooLarge, err)
+}
+
+func TestInbound_Drain(t *testing.T) {
+	server, client := tcpPair(t)
+	decoder := NewLengthFieldBasedFromDecoder(server, 4)
+
+	// the frames arrived together are handled one by one, the partial header is kept
+	last := lengthFrame("last")
+	data := append(append(lengthFrame("foo"), lengthFrame("bar")...), last[:2]...)
+	_, err := client.Write(data)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"foo", "bar"}, drainFrames(t, decoder, 512, 2))
+
+	_, err = client.Write(last[2:])
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"last"}, drainFrames(t, decoder, 512, 1))
+}
+
+func TestInbound_DrainTooLarge(t *testing.T) {
+	server, client := tcpPair(t)
+	decoder := NewLengthFieldBasedFromDecoder(server, 4).(*LengthFieldBasedFrameDecoder)
+
+	// the length field announces 4MiB, which is rejected before the body is buffered
+	header := make([]byte, 4)
+	defaultEndian.PutInt32(header, 4<<20)
+	_, err := client.Write(header)
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool {
+		return decoder.Drain(512, func() error { return nil }) == ErrInvalidLength
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, inboundInitSize, len(decoder.inbound.buf))
+}
+
+func TestInbound_DrainWebsocketTooLarge(t *testing.T) {
+	server, client := tcpPair(t)
+	decoder := NewWebsocketDecoder(server).(*websocketDecoder)
+
+	frame := ws.MaskFrameInPlace(ws.NewBinaryFrame(make([]byte, 1024)))
+	assert.Nil(t, ws.WriteHeader(client, frame.Header))
+	assert.Eventually(t, func() bool {
+		return decoder.Drain(512, func() error { return nil }) == ErrMessageTooLarge
+	}, time.Second, time.Millisecond)
+
+	// the message is closed like Read
+	assert.Nil(t, client.SetReadDeadline(time.Now().Add(time.Second)))
+	closed, err := ws.ReadFrame(client)
+	assert.Nil(t, err)
+	assert.Equal(t, ws.OpClose, closed.Header.OpCode)
+	code, _ := ws.ParseCloseFrameData(closed.Payload)
+	assert.Equal(t, ws.StatusMessageTooBig, code)
+}
+
+func TestInbound_Shrink(t *testing.T) {
+	server, client := tcpPair(t)
+	decoder := NewLengthFieldBasedFromDecoder(server, 4).(*LengthFieldBasedFrameDecoder)
+
+	// the buffer grows for the partial large frame only
+	large := lengthFrame(string(bytes.Repeat([]byte("a"), 3*inboundInitSize)))
+	_, err := client.Write(large[:inboundInitSize])
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool {
+		assert.Nil(t, decoder.Drain(len(large), func() error { return nil }))
+		return len(decoder.inbound.buf) == len(large)
+	}, time.Second, time.Millisecond)
+
+	// the large buffer is released after the frame is handled
+	_, err = client.Write(large[inboundInitSize:])
+	assert.Nil(t, err)
+	frames := drainFrames(t, decoder, len(large), 1)
+	assert.Equal(t, []string{string(large[4:])}, frames)
+	assert.Nil(t, decoder.inbound.buf)
+}
@@ -0,0 +1,39 @@
+//go:build !windows
+
+package codec
+
+import (
+	"syscall"
+)
+
+// fill reads the socket until EAGAIN or the buffer is full, returns true if EAGAIN is reached
+func (in *inbound) fill() (eagain bool, err error) {
+	raw, err := syscallConn(in.conn)
+	if err != nil {
+		return false, err
+	}
+
+	readErr := raw.Read(func(fd uintptr) bool {
+		for in.prepare() {
+			n, readErr := syscall.Read(int(fd), in.buf[in.w:])
+			if readErr == syscall.EINTR {
+				continue
+			}
+			if readErr == syscall.EAGAIN {
+				eagain = true
+				return true
+			}
+			if n <= 0 {
+				err = eof(n, readErr)
+				return true
+			}
+			in.w += n
+		}
+		// the buffer is full
+		return true
+	})
+	if err == nil {
+		err = readErr
+	}
+	return
+}
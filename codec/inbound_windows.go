@@ -0,0 +1,13 @@
+//go:build windows
+
+package codec
+
+// fill reads the socket once, the connection is readable so it won't block
+func (in *inbound) fill() (eagain bool, err error) {
+	if !in.prepare() {
+		return false, nil
+	}
+	n, err := in.conn.Read(in.buf[in.w:])
+	in.w += n
+	return true, err
+}
@@ -1,22 +1,48 @@
 package codec
 
-import "github.com/ebar-go/ego/utils/binary"
+import (
+	stdbinary "encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/ebar-go/ego/utils/binary"
+	"math"
+)
 
 var defaultEndian = binary.BigEndian()
 
-// Options represents options options
-type Options struct {
-	endian binary.Endian
+var (
+	ErrActionOverflow = errors.New("action overflows the width of header")
+	ErrSeqOverflow    = errors.New("seq overflows the width of header")
+)
 
-	headerSize, headerOffset int
-	actionSize, actionOffset int
-	seqSize, seqOffset       int
+// Field represents an extra field of the packet header, like version, flags or trace id
+type Field struct {
+	Name string
+	// Size is the width of the field in bytes
+	Size int
 }
 
-func (options *Options) complete() {
-	options.headerOffset = options.headerSize
-	options.actionOffset = options.actionSize
-	options.seqOffset = options.actionOffset + options.seqSize
+// Options represents the layout of the packet header, which is composed by action, seq and the extra fields in order,
+// the client and server must use the same layout
+type Options struct {
+	// ByteOrder is the byte order of action, seq and the numeric extra fields, default is big endian
+	ByteOrder stdbinary.ByteOrder
+
+	// ActionSize is the width of action in bytes, must be 1, 2 or 4, default is 2
+	ActionSize int
+
+	// SeqSize is the width of seq in bytes, must be 1, 2 or 4, default is 2
+	SeqSize int
+
+	// Fields is the extra fields after seq
+	Fields []Field
+
+	// Compressor compresses the body larger than CompressThreshold, which requires the FlagsField, see WithCompression.
+	// The compressed packets are decompressed by the compressor id in the flags whatever it is
+	Compressor Compressor
+
+	// CompressThreshold is the max size of the body which is sent uncompressed
+	CompressThreshold int
 }
 
 // Default returns the default options implementation,the packet is composed by :
@@ -24,12 +50,157 @@ func (options *Options) complete() {
 // |packetLength| action |      seq     |-------- body --------|
 // |     4      |    2   |       2      |          n           |
 func DefaultOptions() *Options {
-	opts := &Options{
-		endian:     defaultEndian,
-		headerSize: 4,
-		actionSize: 2,
-		seqSize:    2,
-	}
-	opts.complete()
-	return opts
+	return &Options{
+		ByteOrder:  stdbinary.BigEndian,
+		ActionSize: 2,
+		SeqSize:    2,
+	}
+}
+
+// Validate validates the layout
+func (options *Options) Validate() error {
+	if options.ByteOrder == nil {
+		return errors.New("ByteOrder is required")
+	}
+	if !validIntSize(options.ActionSize) {
+		return errors.New("ActionSize must be 1, 2 or 4")
+	}
+	if !validIntSize(options.SeqSize) {
+		return errors.New("SeqSize must be 1, 2 or 4")
+	}
+	names := make(map[string]struct{}, len(options.Fields))
+	for _, field := range options.Fields {
+		if field.Size <= 0 {
+			return fmt.Errorf("size of field %q must be greater than zero", field.Name)
+		}
+		if _, exists := names[field.Name]; exists {
+			return fmt.Errorf("field %q is duplicated", field.Name)
+		}
+		names[field.Name] = struct{}{}
+	}
+	if options.Compressor == nil {
+		return nil
+	}
+	if _, size := options.field(FlagsField); size != 1 && size != 2 && size != 4 && size != 8 {
+		return errors.New("the flags field of 1, 2, 4 or 8 bytes is required by Compressor")
+	}
+	if id := options.Compressor.ID(); id == 0 || id > compressorMask {
+		return errors.New("the id of Compressor must be 1 ~ 7")
+	}
+	return nil
+}
+
+// CheckAction returns ErrActionOverflow if the action can't be represented by ActionSize bytes,
+// which would be truncated in the header
+func (options *Options) CheckAction(action int32) error {
+	if !fitsInt(action, options.ActionSize) {
+		return ErrActionOverflow
+	}
+	return nil
+}
+
+// CheckSeq returns ErrSeqOverflow if the seq can't be represented by SeqSize bytes
+func (options *Options) CheckSeq(seq int32) error {
+	if !fitsInt(seq, options.SeqSize) {
+		return ErrSeqOverflow
+	}
+	return nil
+}
+
+// KeySize returns the size of action and seq, which identify a request
+func (options *Options) KeySize() int {
+	return options.ActionSize + options.SeqSize
+}
+
+// HeaderSize returns the size of the header without the packet length
+func (options *Options) HeaderSize() int {
+	size := options.KeySize()
+	for _, field := range options.Fields {
+		size += field.Size
+	}
+	return size
+}
+
+// field returns the offset and size of the extra field in the header, the offset is -1 if not found
+func (options *Options) field(name string) (offset, size int) {
+	offset = options.KeySize()
+	for _, field := range options.Fields {
+		if field.Name == name {
+			return offset, field.Size
+		}
+		offset += field.Size
+	}
+	return -1, 0
+}
+
+func validIntSize(size int) bool {
+	return size == 1 || size == 2 || size == 4
+}
+
+// fitsInt returns true if v is in the range of the signed integer with the width of size
+func fitsInt(v int32, size int) bool {
+	switch size {
+	case 1:
+		return v >= math.MinInt8 && v <= math.MaxInt8
+	case 2:
+		return v >= math.MinInt16 && v <= math.MaxInt16
+	}
+	return true
+}
+
+// putInt writes v into b with the width of b
+func putInt(order stdbinary.ByteOrder, b []byte, v int32) {
+	switch len(b) {
+	case 1:
+		b[0] = byte(v)
+	case 2:
+		order.PutUint16(b, uint16(v))
+	case 4:
+		order.PutUint32(b, uint32(v))
+	}
+}
+
+// readInt reads the signed integer with the width of b
+func readInt(order stdbinary.ByteOrder, b []byte) int32 {
+	switch len(b) {
+	case 1:
+		return int32(int8(b[0]))
+	case 2:
+		return int32(int16(order.Uint16(b)))
+	case 4:
+		return int32(order.Uint32(b))
+	}
+	return 0
+}
+
+// readUint reads the unsigned integer with the width of b, which must be 1, 2, 4 or 8
+func readUint(order stdbinary.ByteOrder, b []byte) uint64 {
+	switch len(b) {
+	case 1:
+		return uint64(b[0])
+	case 2:
+		return uint64(order.Uint16(b))
+	case 4:
+		return uint64(order.Uint32(b))
+	case 8:
+		return order.Uint64(b)
+	}
+	return 0
+}
+
+// putUint writes v into b with the width of b, returns false if the width is not 1, 2, 4 or 8
+func putUint(order stdbinary.ByteOrder, b []byte, v uint64) bool {
+	switch len(b) {
+	case 1:
+		b[0] = byte(v)
+	case 2:
+		order.PutUint16(b, uint16(v))
+	case 4:
+		order.PutUint32(b, uint32(v))
+	case 8:
+		order.PutUint64(b, v)
+	default:
+		return false
+	}
+	return true
 }
@@ -1,18 +1,28 @@
 package codec
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 type Packet struct {
 	options *Options
 	codec   Codec
 
-	Action int16
-	Seq    int16
+	Action int32
+	Seq    int32
 	Body   []byte
+
+	// fields is the extra fields of the header
+	fields []byte
 }
 
 func NewPacket(codec Codec) *Packet {
-	options := DefaultOptions()
+	return NewPacketWithOptions(codec, DefaultOptions())
+}
+
+// NewPacketWithOptions returns a new packet with the codec and header options
+func NewPacketWithOptions(codec Codec, options *Options) *Packet {
 	return &Packet{codec: codec, options: options}
 }
 
@@ -25,32 +35,106 @@ func (p *Packet) Unmarshal(data any) (err error) {
 	return p.codec.Unmarshal(p.Body, data)
 }
 
+// Pack returns the message of packet, returns error if the action or seq overflows the width of header
 func (p *Packet) Pack() ([]byte, error) {
 	options := p.options
-	// packet header and body
-	length := len(p.Body) + options.headerSize
-	buf := make([]byte, length)
+	if err := options.CheckAction(p.Action); err != nil {
+		return nil, err
+	}
+	if err := options.CheckSeq(p.Seq); err != nil {
+		return nil, err
+	}
+	body, compressor, err := options.compress(p.Body)
+	if err != nil {
+		return nil, err
+	}
 
-	endian := options.endian
-	endian.PutInt16(buf[0:options.actionOffset], p.Action)
-	endian.PutInt16(buf[options.actionOffset:options.seqOffset], p.Seq)
+	// packet header and body
+	headerSize := options.HeaderSize()
+	buf := make([]byte, len(body)+headerSize)
+
+	putInt(options.ByteOrder, buf[:options.ActionSize], p.Action)
+	putInt(options.ByteOrder, buf[options.ActionSize:options.KeySize()], p.Seq)
+	// the extra fields are zero if not set
+	copy(buf[options.KeySize():headerSize], p.fields)
+	if offset, size := options.field(FlagsField); offset >= 0 {
+		flags := buf[offset : offset+size]
+		putUint(options.ByteOrder, flags, readUint(options.ByteOrder, flags)&^compressorMask|uint64(compressor))
+	}
 
-	copy(buf[options.headerSize:], p.Body)
+	copy(buf[headerSize:], body)
 	return buf, nil
 }
 
 func (p *Packet) Unpack(msg []byte) error {
 	options := p.options
-	if len(msg) < options.headerOffset {
+	headerSize := options.HeaderSize()
+	if len(msg) < headerSize {
 		return errors.New("msg is too short")
 	}
-	p.Action = options.endian.Int16(msg[0:options.actionOffset])
-	p.Seq = options.endian.Int16(msg[options.actionOffset:options.seqOffset])
-	p.Body = msg[options.headerOffset:]
+	p.Action = readInt(options.ByteOrder, msg[:options.ActionSize])
+	p.Seq = readInt(options.ByteOrder, msg[options.ActionSize:options.KeySize()])
+	p.fields = msg[options.KeySize():headerSize]
+	p.Body = msg[headerSize:]
+
+	// the body is decompressed if the flags is marked
+	if offset, size := options.field(FlagsField); offset >= 0 {
+		compressor := byte(readUint(options.ByteOrder, msg[offset:offset+size]) & compressorMask)
+		body, err := decompress(compressor, p.Body)
+		if err != nil {
+			return err
+		}
+		p.Body = body
+	}
+	return nil
+}
+
+// Field returns the value of the extra field, returns nil if the field is not defined
+func (p *Packet) Field(name string) []byte {
+	offset, size := p.options.field(name)
+	if offset < 0 {
+		return nil
+	}
+	p.initFields()
+	offset -= p.options.KeySize()
+	return p.fields[offset : offset+size]
+}
 
+// SetField sets the value of the extra field, the size of value must be the same as the field
+func (p *Packet) SetField(name string, value []byte) error {
+	field := p.Field(name)
+	if field == nil {
+		return fmt.Errorf("field %q is not defined", name)
+	}
+	if len(value) != len(field) {
+		return fmt.Errorf("size of field %q is %d, got %d", name, len(field), len(value))
+	}
+	copy(field, value)
 	return nil
 }
 
+// FieldUint returns the extra field as an unsigned integer, the size of field must be 1, 2, 4 or 8
+func (p *Packet) FieldUint(name string) uint64 {
+	return readUint(p.options.ByteOrder, p.Field(name))
+}
+
+// SetFieldUint sets the extra field as an unsigned integer, the size of field must be 1, 2, 4 or 8
+func (p *Packet) SetFieldUint(name string, v uint64) error {
+	if !putUint(p.options.ByteOrder, p.Field(name), v) {
+		return fmt.Errorf("field %q is not an integer", name)
+	}
+	return nil
+}
+
+// initFields allocates the extra fields of the new packet
+func (p *Packet) initFields() {
+	if size := p.options.HeaderSize() - p.options.KeySize(); len(p.fields) != size {
+		fields := make([]byte, size)
+		copy(fields, p.fields)
+		p.fields = fields
+	}
+}
+
 func (p *Packet) Encode(data any) (msg []byte, err error) {
 	p.Body, err = p.codec.Marshal(data)
 	if err != nil {
@@ -60,7 +144,7 @@ func (p *Packet) Encode(data any) (msg []byte, err error) {
 	return p.Pack()
 }
 
-func (p *Packet) EncodeWith(action, seq int16, data any) ([]byte, error) {
+func (p *Packet) EncodeWith(action, seq int32, data any) ([]byte, error) {
 	p.Action = action
 	p.Seq = seq
 
@@ -0,0 +1,30 @@
+package codec
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPacket_PackOverflow(t *testing.T) {
+	packet := NewPacket(NewJsonCodec())
+	_, err := packet.EncodeWith(70000, 1, nil)
+	assert.Equal(t, ErrActionOverflow, err)
+	_, err = packet.EncodeWith(1, 40000, nil)
+	assert.Equal(t, ErrSeqOverflow, err)
+
+	// the negative values are sign-extended when read back
+	msg, err := packet.EncodeWith(-1, -2, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, packet.Unpack(msg))
+	assert.Equal(t, int32(-1), packet.Action)
+	assert.Equal(t, int32(-2), packet.Seq)
+
+	options := &Options{ByteOrder: DefaultOptions().ByteOrder, ActionSize: 4, SeqSize: 1}
+	packet = NewPacketWithOptions(NewJsonCodec(), options)
+	msg, err = packet.EncodeWith(70000, 127, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, packet.Unpack(msg))
+	assert.Equal(t, int32(70000), packet.Action)
+	_, err = packet.EncodeWith(70000, 128, nil)
+	assert.Equal(t, ErrSeqOverflow, err)
+}
@@ -0,0 +1,391 @@
+package codec
+
+import (
+	"github.com/ebar-go/ego/utils/binary"
+	"github.com/ebar-go/ego/utils/runtime"
+	"github.com/lucas-clemente/quic-go"
+	"io"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// quicLengthSize is the size of the length field of the frames on quic streams
+	quicLengthSize = 4
+	// quicMaxFrameSize is the max size of the frames on quic streams
+	quicMaxFrameSize = 4 << 20
+	// quicInboxSize is the count of the frames received but not read
+	quicInboxSize = 64
+)
+
+// QUICOptions represents the options of the quic decoder
+type QUICOptions struct {
+	// StreamPerRequest answers every request on the stream where it came from
+	StreamPerRequest bool
+
+	// Packet is the header layout, the requests are identified by action and seq, default is DefaultOptions()
+	Packet *Options
+}
+
+// quicDecoder keeps a long-lived control stream per connection, the frames are prefixed with the length field.
+// The client opens the control stream, which is accepted by the server.
+// If streamPerRequest is enabled, the client opens a new stream for every request,
+// and the server writes the response to the stream where the request came from,
+// so the requests are processed in parallel without head-of-line blocking.
+// The server matches the response with the request by the action and seq of the packet header,
+// the pushes of the server are always written to the control stream.
+// The request stream without response is closed by Finish, and the client sends the request on the control stream
+// if the peer doesn't allow more streams, so the unanswered requests never block the connection.
+type quicDecoder struct {
+	conn             quic.Connection
+	isClient         bool
+	streamPerRequest bool
+	endian           binary.Endian
+	// keySize is the size of the packet header which identifies a request, composed by action and seq
+	keySize int
+
+	// control is the control stream, ready is closed once it is opened or accepted
+	control quic.Stream
+	ready   chan struct{}
+	// writeMu make sure the frames of control stream are not interleaved
+	writeMu sync.Mutex
+
+	// inbox is the frames received from all streams
+	inbox chan []byte
+
+	// pending is the request streams waiting for the response
+	mu      sync.Mutex
+	pending map[string]quic.Stream
+
+	once sync.Once
+	done chan struct{}
+	err  error
+}
+
+func newQUICDecoder(conn quic.Connection, isClient bool, options QUICOptions) *quicDecoder {
+	if options.Packet == nil {
+		options.Packet = DefaultOptions()
+	}
+	decoder := &quicDecoder{
+		conn:             conn,
+		isClient:         isClient,
+		streamPerRequest: options.StreamPerRequest,
+		endian:           defaultEndian,
+		keySize:          options.Packet.KeySize(),
+		ready:            make(chan struct{}),
+		inbox:            make(chan []byte, quicInboxSize),
+		pending:          make(map[string]quic.Stream),
+		done:             make(chan struct{}),
+	}
+	if isClient {
+		// open the control stream before any request stream, so the server accepts it first
+		decoder.open()
+		return decoder
+	}
+
+	go func() {
+		defer runtime.HandleCrash()
+		decoder.serve()
+	}()
+	return decoder
+}
+
+// NewQUICDecoder returns the server side decoder which uses the control stream only
+func NewQUICDecoder(conn quic.Connection) net.Conn {
+	return newQUICDecoder(conn, false, QUICOptions{})
+}
+
+// NewQUICStreamDecoder returns the server side decoder which answers every request on its own stream
+func NewQUICStreamDecoder(conn quic.Connection) net.Conn {
+	return newQUICDecoder(conn, false, QUICOptions{StreamPerRequest: true})
+}
+
+// NewQUICDecoderWithOptions returns the server side decoder with options
+func NewQUICDecoderWithOptions(conn quic.Connection, options QUICOptions) net.Conn {
+	return newQUICDecoder(conn, false, options)
+}
+
+// NewQUICClientDecoder returns the client side decoder which uses the control stream only
+func NewQUICClientDecoder(conn quic.Connection) net.Conn {
+	return newQUICDecoder(conn, true, QUICOptions{})
+}
+
+// NewQUICStreamClientDecoder returns the client side decoder which sends every request on a new stream
+func NewQUICStreamClientDecoder(conn quic.Connection) net.Conn {
+	return newQUICDecoder(conn, true, QUICOptions{StreamPerRequest: true})
+}
+
+// NewQUICClientDecoderWithOptions returns the client side decoder with options
+func NewQUICClientDecoderWithOptions(conn quic.Connection, options QUICOptions) net.Conn {
+	return newQUICDecoder(conn, true, options)
+}
+
+// open opens the control stream of the client
+func (decoder *quicDecoder) open() {
+	stream, err := decoder.conn.OpenStreamSync(decoder.conn.Context())
+	if err == nil {
+		// the stream is invisible to the server until the first frame is sent, so send an empty frame
+		_, err = writeFrame(stream, quicLengthSize, decoder.endian, nil)
+	}
+	if err != nil {
+		decoder.fail(err)
+		return
+	}
+	decoder.control = stream
+	close(decoder.ready)
+
+	go func() {
+		defer runtime.HandleCrash()
+		decoder.fail(decoder.receive(stream))
+	}()
+}
+
+// serve accepts the control stream of the server, then accepts the request streams if streamPerRequest is enabled
+func (decoder *quicDecoder) serve() {
+	ctx := decoder.conn.Context()
+	stream, err := decoder.conn.AcceptStream(ctx)
+	if err != nil {
+		decoder.fail(err)
+		return
+	}
+	decoder.control = stream
+	close(decoder.ready)
+
+	go func() {
+		defer runtime.HandleCrash()
+		decoder.fail(decoder.receive(stream))
+	}()
+
+	if !decoder.streamPerRequest {
+		return
+	}
+	for {
+		stream, err := decoder.conn.AcceptStream(ctx)
+		if err != nil {
+			decoder.fail(err)
+			return
+		}
+		go func() {
+			defer runtime.HandleCrash()
+			decoder.receiveRequest(stream)
+		}()
+	}
+}
+
+// receive reads the frames of the stream until error
+func (decoder *quicDecoder) receive(stream quic.Stream) error {
+	for {
+		buf, err := decoder.readFrame(stream)
+		if err != nil {
+			return err
+		}
+		// skip the empty frame which opens the control stream
+		if len(buf) == 0 {
+			continue
+		}
+		if !decoder.deliver(buf) {
+			return nil
+		}
+	}
+}
+
+// receiveRequest reads the only request of the stream, the stream is kept until the response is written
+// or the request is finished without response
+func (decoder *quicDecoder) receiveRequest(stream quic.Stream) {
+	buf, err := decoder.readFrame(stream)
+	// nothing else is read from the stream, the receive side is done
+	stream.CancelRead(0)
+	if err != nil || len(buf) < decoder.keySize {
+		stream.CancelWrite(0)
+		return
+	}
+
+	key := string(buf[:decoder.keySize])
+	decoder.mu.Lock()
+	// the previous request with the same action and seq won't get a response any more
+	if previous, ok := decoder.pending[key]; ok {
+		previous.CancelWrite(0)
+	}
+	decoder.pending[key] = stream
+	decoder.mu.Unlock()
+
+	decoder.deliver(buf)
+}
+
+// receiveResponse reads the response of the request stream
+func (decoder *quicDecoder) receiveResponse(stream quic.Stream) {
+	buf, err := decoder.readFrame(stream)
+	stream.CancelRead(0)
+	if err != nil {
+		return
+	}
+	decoder.deliver(buf)
+}
+
+// deliver puts the frame into inbox, returns false if the decoder is closed
+func (decoder *quicDecoder) deliver(buf []byte) bool {
+	select {
+	case decoder.inbox <- buf:
+		return true
+	case <-decoder.done:
+		return false
+	}
+}
+
+func (decoder *quicDecoder) readFrame(stream quic.Stream) ([]byte, error) {
+	length, err := readLengthField(stream, quicLengthSize, decoder.endian)
+	if err != nil {
+		return nil, err
+	}
+	if length < quicLengthSize || length > quicMaxFrameSize {
+		return nil, ErrInvalidLength
+	}
+
+	buf := make([]byte, length-quicLengthSize)
+	if _, err = io.ReadFull(stream, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// fail stops the decoder with the first error
+func (decoder *quicDecoder) fail(err error) {
+	decoder.once.Do(func() {
+		if err == nil {
+			err = io.EOF
+		}
+		decoder.err = err
+		close(decoder.done)
+
+		decoder.mu.Lock()
+		for key, stream := range decoder.pending {
+			stream.CancelWrite(0)
+			delete(decoder.pending, key)
+		}
+		decoder.mu.Unlock()
+	})
+}
+
+func (decoder *quicDecoder) Read(b []byte) (n int, err error) {
+	select {
+	case buf := <-decoder.inbox:
+		if len(buf) > len(b) {
+			return 0, ErrInvalidLength
+		}
+		return copy(b, buf), nil
+	case <-decoder.done:
+		return 0, decoder.err
+	}
+}
+
+func (decoder *quicDecoder) Write(b []byte) (n int, err error) {
+	if decoder.streamPerRequest {
+		if decoder.isClient {
+			return decoder.writeRequest(b)
+		}
+		if stream := decoder.takePending(b); stream != nil {
+			n, err = writeFrame(stream, quicLengthSize, decoder.endian, b)
+			// close the send direction, the stream is done
+			_ = stream.Close()
+			return
+		}
+	}
+
+	return decoder.writeControl(b)
+}
+
+// writeControl writes the frame to the control stream
+func (decoder *quicDecoder) writeControl(b []byte) (n int, err error) {
+	select {
+	case <-decoder.ready:
+	case <-decoder.done:
+		return 0, decoder.err
+	}
+	decoder.writeMu.Lock()
+	defer decoder.writeMu.Unlock()
+	return writeFrame(decoder.control, quicLengthSize, decoder.endian, b)
+}
+
+// writeRequest sends the request on a new stream and waits for the response in background,
+// the request is sent on the control stream if the streams opened reach the limit of peer
+func (decoder *quicDecoder) writeRequest(b []byte) (n int, err error) {
+	stream, err := decoder.conn.OpenStream()
+	if tooMany, ok := err.(interface{ Temporary() bool }); ok && tooMany.Temporary() {
+		return decoder.writeControl(b)
+	}
+	if err != nil {
+		return
+	}
+	n, err = writeFrame(stream, quicLengthSize, decoder.endian, b)
+	if err != nil {
+		stream.CancelWrite(0)
+		stream.CancelRead(0)
+		return
+	}
+	_ = stream.Close()
+
+	go func() {
+		defer runtime.HandleCrash()
+		decoder.receiveResponse(stream)
+	}()
+	return
+}
+
+// takePending returns the stream of the request which the response belongs to
+func (decoder *quicDecoder) takePending(b []byte) quic.Stream {
+	if len(b) < decoder.keySize {
+		return nil
+	}
+	key := string(b[:decoder.keySize])
+	decoder.mu.Lock()
+	defer decoder.mu.Unlock()
+	stream, ok := decoder.pending[key]
+	if !ok {
+		return nil
+	}
+	delete(decoder.pending, key)
+	return stream
+}
+
+// Finish implements Finisher, the request stream which has no response is closed,
+// so the client stops waiting for it and the stream is released
+func (decoder *quicDecoder) Finish(header []byte) {
+	if !decoder.streamPerRequest || decoder.isClient {
+		return
+	}
+	if stream := decoder.takePending(header); stream != nil {
+		_ = stream.Close()
+	}
+}
+
+func (decoder *quicDecoder) Close() error {
+	decoder.fail(net.ErrClosed)
+	return decoder.conn.CloseWithError(0, "")
+}
+
+func (decoder *quicDecoder) LocalAddr() net.Addr {
+	return decoder.conn.LocalAddr()
+}
+
+func (decoder *quicDecoder) RemoteAddr() net.Addr {
+	return decoder.conn.RemoteAddr()
+}
+
+func (decoder *quicDecoder) SetDeadline(t time.Time) error {
+	return nil
+}
+
+func (decoder *quicDecoder) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (decoder *quicDecoder) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+// SyscallConn prepare for epoll
+func (decoder *quicDecoder) SyscallConn() (syscall.RawConn, error) {
+	return nil, ErrSyscallConnUnsupported
+}
@@ -0,0 +1,178 @@
+package codec
+
+import (
+	"context"
+	"crypto/tls"
+	"github.com/lucas-clemente/quic-go"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const testQUICProtocol = "znet-test"
+
+// quicPair returns the server and client side connections of quic
+func quicPair(t *testing.T) (server, client quic.Connection) {
+	s := httptest.NewUnstartedServer(nil)
+	s.StartTLS()
+	serverConf := s.TLS.Clone()
+	s.Close()
+	serverConf.NextProtos = []string{testQUICProtocol}
+
+	ln, err := quic.ListenAddr("127.0.0.1:0", serverConf, nil)
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+
+	accepted := make(chan quic.Connection, 1)
+	go func() {
+		conn, err := ln.Accept(context.Background())
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+
+	client, err = quic.DialAddr(ln.Addr().String(), &tls.Config{
+		InsecureSkipVerify: true,
+		NextProtos:         []string{testQUICProtocol},
+	}, nil)
+	assert.Nil(t, err)
+	server = <-accepted
+	t.Cleanup(func() {
+		_ = client.CloseWithError(0, "")
+		_ = server.CloseWithError(0, "")
+	})
+	return
+}
+
+func encode(t *testing.T, options *Options, action, seq int32, body string) []byte {
+	msg, err := NewPacketWithOptions(NewJsonCodec(), options).EncodeWith(action, seq, body)
+	assert.Nil(t, err)
+	return msg
+}
+
+func decode(t *testing.T, options *Options, conn net.Conn) *Packet {
+	buf := make([]byte, 512)
+	n, err := conn.Read(buf)
+	assert.Nil(t, err)
+	packet := NewPacketWithOptions(NewJsonCodec(), options)
+	assert.Nil(t, packet.Unpack(buf[:n]))
+	return packet
+}
+
+func TestQUICDecoder_ControlStream(t *testing.T) {
+	serverConn, clientConn := quicPair(t)
+	options := DefaultOptions()
+	client := NewQUICClientDecoder(clientConn)
+	server := NewQUICDecoder(serverConn)
+
+	// the frames of the control stream are kept in order
+	for seq := int32(1); seq <= 3; seq++ {
+		_, err := client.Write(encode(t, options, 1, seq, "ping"))
+		assert.Nil(t, err)
+	}
+	for seq := int32(1); seq <= 3; seq++ {
+		packet := decode(t, options, server)
+		assert.Equal(t, int32(1), packet.Action)
+		assert.Equal(t, seq, packet.Seq)
+	}
+
+	_, err := server.Write(encode(t, options, 1, 1, "pong"))
+	assert.Nil(t, err)
+	_, err = server.Write(encode(t, options, 2, 0, "push"))
+	assert.Nil(t, err)
+	assert.Equal(t, `"pong"`, string(decode(t, options, client).Body))
+	assert.Equal(t, `"push"`, string(decode(t, options, client).Body))
+
+	// the reads fail after closed
+	assert.Nil(t, client.Close())
+	_, err = server.Read(make([]byte, 512))
+	assert.NotNil(t, err)
+}
+
+func TestQUICDecoder_StreamPerRequest(t *testing.T) {
+	serverConn, clientConn := quicPair(t)
+	// the key of request is composed by the 4 bytes action and 1 byte seq
+	options := &Options{ByteOrder: DefaultOptions().ByteOrder, ActionSize: 4, SeqSize: 1}
+	quicOptions := QUICOptions{StreamPerRequest: true, Packet: options}
+	client := NewQUICClientDecoderWithOptions(clientConn, quicOptions)
+	server := NewQUICDecoderWithOptions(serverConn, quicOptions).(*quicDecoder)
+
+	// the requests of the same action are distinguished by seq
+	_, err := client.Write(encode(t, options, 70000, 1, "a"))
+	assert.Nil(t, err)
+	_, err = client.Write(encode(t, options, 70000, 2, "b"))
+	assert.Nil(t, err)
+
+	requests := map[int32]string{}
+	for i := 0; i < 2; i++ {
+		packet := decode(t, options, server)
+		assert.Equal(t, int32(70000), packet.Action)
+		requests[packet.Seq] = string(packet.Body)
+	}
+	assert.Equal(t, map[int32]string{1: `"a"`, 2: `"b"`}, requests)
+	assert.Len(t, server.pending, 2)
+
+	// the responses are written to the streams of requests, the push is written to the control stream
+	_, err = server.Write(encode(t, options, 70000, 2, "B"))
+	assert.Nil(t, err)
+	_, err = server.Write(encode(t, options, 70000, 1, "A"))
+	assert.Nil(t, err)
+	_, err = server.Write(encode(t, options, 3, 0, "push"))
+	assert.Nil(t, err)
+	assert.Empty(t, server.pending)
+
+	responses := map[int32]string{}
+	for i := 0; i < 3; i++ {
+		packet := decode(t, options, client)
+		responses[packet.Seq] = string(packet.Body)
+	}
+	assert.Equal(t, map[int32]string{1: `"A"`, 2: `"B"`, 0: `"push"`}, responses)
+}
+
+func TestQUICDecoder_StreamPerRequestUnanswered(t *testing.T) {
+	serverConn, clientConn := quicPair(t)
+	options := DefaultOptions()
+	quicOptions := QUICOptions{StreamPerRequest: true}
+	client := NewQUICClientDecoderWithOptions(clientConn, quicOptions)
+	server := NewQUICDecoderWithOptions(serverConn, quicOptions).(*quicDecoder)
+
+	// more requests than the 100 streams allowed by the default config of quic
+	const count = 300
+	written := make(chan error, 1)
+	send := func() {
+		for seq := int32(1); seq <= count; seq++ {
+			if _, err := client.Write(encode(t, options, 1, seq, "ping")); err != nil {
+				written <- err
+				return
+			}
+		}
+		written <- nil
+	}
+
+	// the requests finished without response release their streams
+	go send()
+	buf := make([]byte, 512)
+	for i := 0; i < count; i++ {
+		n, err := server.Read(buf)
+		assert.Nil(t, err)
+		server.Finish(buf[:n])
+	}
+	assert.Nil(t, <-written)
+	assert.Empty(t, server.pending)
+
+	// the client doesn't wait for the streams never answered, the requests go through the control stream
+	go send()
+	for i := 0; i < count; i++ {
+		_, err := server.Read(buf)
+		assert.Nil(t, err)
+	}
+	select {
+	case err := <-written:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the requests are blocked by the unanswered streams")
+	}
+	assert.Less(t, len(server.pending), count)
+}
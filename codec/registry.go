@@ -0,0 +1,64 @@
+package codec
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+const (
+	ContentTypeJson    = "json"
+	ContentTypeProto   = "protobuf"
+	ContentTypeMsgpack = "msgpack"
+	ContentTypeCBOR    = "cbor"
+	ContentTypeRaw     = "raw"
+)
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = make(map[string]func() Codec)
+)
+
+func init() {
+	Register(ContentTypeJson, func() Codec { return NewJsonCodec() })
+	Register(ContentTypeProto, func() Codec { return NewProtoCodec() })
+	Register(ContentTypeMsgpack, func() Codec { return NewMsgpackCodec() })
+	Register(ContentTypeCBOR, func() Codec { return NewCBORCodec() })
+	Register(ContentTypeRaw, func() Codec { return NewRawCodec() })
+}
+
+// Register makes the codec available by the content type, it panics if the content type is registered twice
+func Register(contentType string, factory func() Codec) {
+	if contentType == "" || factory == nil {
+		panic("codec: Register content type is empty or factory is nil")
+	}
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	if _, exists := codecs[contentType]; exists {
+		panic("codec: Register called twice for content type " + contentType)
+	}
+	codecs[contentType] = factory
+}
+
+// ContentTypes returns the sorted names of the registered codecs
+func ContentTypes() []string {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	contentTypes := make([]string, 0, len(codecs))
+	for contentType := range codecs {
+		contentTypes = append(contentTypes, contentType)
+	}
+	sort.Strings(contentTypes)
+	return contentTypes
+}
+
+// New returns the codec of the content type, returns error if it is not registered
+func New(contentType string) (Codec, error) {
+	codecsMu.RLock()
+	factory, ok := codecs[contentType]
+	codecsMu.RUnlock()
+	if !ok {
+		return nil, errors.New("unsupported content type: " + contentType)
+	}
+	return factory(), nil
+}
@@ -0,0 +1,306 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unicode/utf8"
+)
+
+var (
+	ErrTextFrameUnsupported = errors.New("text frame is unsupported")
+	ErrMessageTooLarge      = errors.New("message is too large")
+)
+
+// WebsocketOptions represents the options of the websocket decoder
+type WebsocketOptions struct {
+	// AcceptText accepts the text frames besides the binary frames, the payload is the same packet,
+	// which must be valid UTF-8 or the connection is failed with StatusInvalidFramePayloadData.
+	// So the text clients use a text-safe layout: every byte of the header is less than 0x80,
+	// like the actions and seqs less than 128, and the body is JSON without compression.
+	// The messages written to the connection which has sent text frames are text frames if they are valid UTF-8
+	AcceptText bool
+}
+
+// websocketDecoder reads a whole message per Read, the fragmented messages are reassembled,
+// the control frames are answered automatically
+type websocketDecoder struct {
+	net.Conn
+	isClient bool
+	options  WebsocketOptions
+	state    ws.State
+	reader   *wsutil.Reader
+	inbound  *inbound
+
+	// text is 1 if the peer has sent text frames, the messages of valid UTF-8 are written as text frames
+	text int32
+
+	// writeMu make sure the data frames and control frames are not interleaved
+	writeMu sync.Mutex
+	// closing is true if the close frame is sent or received
+	closing bool
+
+	mu     sync.Mutex
+	code   ws.StatusCode
+	reason string
+
+	// properties is the information of the upgrade request
+	properties map[string]string
+}
+
+func NewWebsocketDecoder(conn net.Conn) net.Conn {
+	return NewWebsocketDecoderWithOptions(conn, WebsocketOptions{})
+}
+
+// NewWebsocketDecoderWithOptions returns the server side decoder with options
+func NewWebsocketDecoderWithOptions(conn net.Conn, options WebsocketOptions) net.Conn {
+	return newWebsocketDecoder(conn, false, options)
+}
+
+// NewWebsocketDecoderWithProperties returns the server side decoder carrying the properties of the upgrade request,
+// which are copied to the connection
+func NewWebsocketDecoderWithProperties(conn net.Conn, options WebsocketOptions, properties map[string]string) net.Conn {
+	decoder := newWebsocketDecoder(conn, false, options)
+	decoder.properties = properties
+	return decoder
+}
+
+func NewWebsocketClientDecoder(conn net.Conn) net.Conn {
+	return newWebsocketDecoder(conn, true, WebsocketOptions{})
+}
+
+func newWebsocketDecoder(conn net.Conn, isClient bool, options WebsocketOptions) *websocketDecoder {
+	decoder := &websocketDecoder{
+		Conn:     conn,
+		isClient: isClient,
+		options:  options,
+		state:    ws.StateServerSide,
+		inbound:  newInbound(conn),
+	}
+	if isClient {
+		decoder.state = ws.StateClientSide
+	}
+	decoder.reader = &wsutil.Reader{
+		Source: decoder.inbound,
+		State:  decoder.state,
+		// the text frames must be valid UTF-8 as RFC 6455 required, see WebsocketOptions.AcceptText
+		CheckUTF8: true,
+		// the control frames between the fragments
+		OnIntermediate: decoder.handleControl,
+	}
+	return decoder
+}
+
+// SyscallConn prepare for epoll
+func (c *websocketDecoder) SyscallConn() (syscall.RawConn, error) {
+	return syscallConn(c.Conn)
+}
+
+// NetConn returns the underlying connection
+func (c *websocketDecoder) NetConn() net.Conn {
+	return c.Conn
+}
+
+// Properties returns the properties of the upgrade request
+func (c *websocketDecoder) Properties() map[string]string {
+	return c.properties
+}
+
+// Read reads the next frame, returns zero without error if it is a control frame,
+// otherwise reads the whole message into p
+func (c *websocketDecoder) Read(p []byte) (n int, err error) {
+	hdr, err := c.reader.NextFrame()
+	if err != nil {
+		return
+	}
+
+	if hdr.OpCode.IsControl() {
+		err = c.handleControl(hdr, c.reader)
+		return
+	}
+
+	if hdr.OpCode == ws.OpText && !c.options.AcceptText {
+		_ = c.reader.Discard()
+		_ = c.WriteClose(uint16(ws.StatusUnsupportedData), ErrTextFrameUnsupported.Error())
+		return 0, ErrTextFrameUnsupported
+	}
+
+	// the fragments are joined by reader
+	if n, err = c.readMessage(p); err != nil {
+		switch err {
+		case ErrMessageTooLarge:
+			_ = c.reader.Discard()
+			_ = c.WriteClose(uint16(ws.StatusMessageTooBig), err.Error())
+		case wsutil.ErrInvalidUTF8:
+			_ = c.WriteClose(uint16(ws.StatusInvalidFramePayloadData), err.Error())
+		}
+		return 0, err
+	}
+	if hdr.OpCode == ws.OpText {
+		atomic.StoreInt32(&c.text, 1)
+	}
+	return
+}
+
+// readMessage reads the payload of message into p until io.EOF
+func (c *websocketDecoder) readMessage(p []byte) (n int, err error) {
+	var m int
+	for n < len(p) {
+		m, err = c.reader.Read(p[n:])
+		n += m
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	// p is full, make sure the message is completed
+	var extra [1]byte
+	for {
+		m, err = c.reader.Read(extra[:])
+		if m > 0 {
+			return n, ErrMessageTooLarge
+		}
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Drain implements Drainer, the message larger than maxSize is closed with StatusMessageTooBig like Read
+func (c *websocketDecoder) Drain(maxSize int, handle func() error) error {
+	err := c.inbound.drain(func(p []byte) (int, bool, error) {
+		return c.complete(p, maxSize)
+	}, handle)
+	if err == ErrMessageTooLarge {
+		_ = c.WriteClose(uint16(ws.StatusMessageTooBig), err.Error())
+	}
+	return err
+}
+
+// complete returns the size of the frames read by the next Read, which are a control frame or the whole message
+// including the interleaved control frames. The payload of message is limited by maxSize,
+// and the headers and control frames may take inboundInitSize bytes more
+func (c *websocketDecoder) complete(p []byte, maxSize int) (int, bool, error) {
+	size, payload := 0, 0
+	for {
+		hdr, err := ws.ReadHeader(bytes.NewReader(p[size:]))
+		if err != nil {
+			// the header is partial
+			return len(p) + 1, false, nil
+		}
+		first := size == 0
+		if !hdr.OpCode.IsControl() {
+			if hdr.Length > int64(maxSize-payload) {
+				return 0, false, ErrMessageTooLarge
+			}
+			payload += int(hdr.Length)
+		}
+		if hdr.Length > int64(maxSize+inboundInitSize-size) {
+			return 0, false, ErrMessageTooLarge
+		}
+		size += ws.HeaderSize(hdr) + int(hdr.Length)
+		if size > maxSize+inboundInitSize {
+			return 0, false, ErrMessageTooLarge
+		}
+		if size > len(p) {
+			return size, false, nil
+		}
+		if first && hdr.OpCode.IsControl() || hdr.Fin && !hdr.OpCode.IsControl() {
+			return size, true, nil
+		}
+	}
+}
+
+// handleControl answers ping with pong and close with close, the status of close frame is recorded
+func (c *websocketDecoder) handleControl(hdr ws.Header, r io.Reader) error {
+	// read the payload before locking, it is no more than 125 bytes
+	payload := make([]byte, hdr.Length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return err
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if hdr.OpCode == ws.OpClose && c.closing {
+		// the reply of the close frame sent by us
+		code, reason := ws.ParseCloseFrameData(payload)
+		return wsutil.ClosedError{Code: code, Reason: reason}
+	}
+
+	err := wsutil.ControlFrameHandler(c.Conn, c.state)(hdr, bytes.NewReader(payload))
+	if closed, ok := err.(wsutil.ClosedError); ok {
+		c.closing = true
+		c.setStatus(closed.Code, closed.Reason)
+	}
+	return err
+}
+
+func (c *websocketDecoder) Write(p []byte) (n int, err error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	op := ws.OpBinary
+	if atomic.LoadInt32(&c.text) == 1 && utf8.Valid(p) {
+		op = ws.OpText
+	}
+	if err = wsutil.WriteMessage(c.Conn, c.state, op, p); err != nil {
+		return
+	}
+	n = len(p)
+	return
+}
+
+// WriteClose sends the close frame with the status code and reason,
+// it is skipped if the close frame is sent or received already
+func (c *websocketDecoder) WriteClose(code uint16, reason string) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closing {
+		return nil
+	}
+	c.closing = true
+	c.setStatus(ws.StatusCode(code), reason)
+
+	frame := ws.NewCloseFrame(ws.NewCloseFrameBody(ws.StatusCode(code), reason))
+	if c.isClient {
+		frame = ws.MaskFrameInPlace(frame)
+	}
+	return ws.WriteFrame(c.Conn, frame)
+}
+
+// CloseStatus returns the status code and reason of the close frame sent or received,
+// the code is zero if there is no close frame
+func (c *websocketDecoder) CloseStatus() (code uint16, reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return uint16(c.code), c.reason
+}
+
+func (c *websocketDecoder) setStatus(code ws.StatusCode, reason string) {
+	c.mu.Lock()
+	c.code, c.reason = code, reason
+	c.mu.Unlock()
+}
+
+// CloseWithStatus sends the close frame with the status code and reason, then closes the connection
+func (c *websocketDecoder) CloseWithStatus(code uint16, reason string) error {
+	_ = c.WriteClose(code, reason)
+	return c.Conn.Close()
+}
+
+// Close sends the normal closure frame if no close frame is sent or received, then closes the connection
+func (c *websocketDecoder) Close() error {
+	return c.CloseWithStatus(uint16(ws.StatusNormalClosure), "")
+}
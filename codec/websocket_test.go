@@ -0,0 +1,88 @@
+package codec
+
+import (
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+func TestWebsocketDecoder_AcceptText(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	decoder := NewWebsocketDecoderWithOptions(server, WebsocketOptions{AcceptText: true})
+
+	// the header of small action and seq is valid UTF-8
+	msg, err := NewPacket(NewJsonCodec()).EncodeWith(1, 1, "ping")
+	assert.Nil(t, err)
+	go func() {
+		// the payload is masked in place, so copy it
+		_ = ws.WriteFrame(client, ws.MaskFrameInPlace(ws.NewTextFrame(append([]byte(nil), msg...))))
+	}()
+
+	p := make([]byte, 512)
+	n, err := decoder.Read(p)
+	assert.Nil(t, err)
+	assert.Equal(t, msg, p[:n])
+
+	// the response to the text client is a text frame
+	go func() {
+		_, _ = decoder.Write(msg)
+	}()
+	frame, err := ws.ReadFrame(client)
+	assert.Nil(t, err)
+	assert.Equal(t, ws.OpText, frame.Header.OpCode)
+	assert.Equal(t, msg, frame.Payload)
+
+	// the message which is not valid UTF-8 is still a binary frame, like the action 128
+	push, err := NewPacket(NewJsonCodec()).EncodeWith(128, 0, "push")
+	assert.Nil(t, err)
+	go func() {
+		_, _ = decoder.Write(push)
+	}()
+	frame, err = ws.ReadFrame(client)
+	assert.Nil(t, err)
+	assert.Equal(t, ws.OpBinary, frame.Header.OpCode)
+	assert.Equal(t, push, frame.Payload)
+}
+
+func TestWebsocketDecoder_InvalidText(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	decoder := NewWebsocketDecoderWithOptions(server, WebsocketOptions{AcceptText: true})
+
+	// the action 128 makes the header invalid UTF-8, which fails the connection
+	msg, err := NewPacket(NewJsonCodec()).EncodeWith(128, 1, "ping")
+	assert.Nil(t, err)
+	closed := make(chan ws.Frame, 1)
+	go func() {
+		_ = ws.WriteFrame(client, ws.MaskFrameInPlace(ws.NewTextFrame(msg)))
+		frame, _ := ws.ReadFrame(client)
+		closed <- frame
+	}()
+
+	_, err = decoder.Read(make([]byte, 512))
+	assert.Equal(t, wsutil.ErrInvalidUTF8, err)
+	frame := <-closed
+	assert.Equal(t, ws.OpClose, frame.Header.OpCode)
+	code, _ := ws.ParseCloseFrameData(frame.Payload)
+	assert.Equal(t, ws.StatusInvalidFramePayloadData, code)
+}
+
+func TestWebsocketDecoder_RejectText(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	decoder := NewWebsocketDecoder(server)
+	defer decoder.Close()
+
+	go func() {
+		_ = ws.WriteFrame(client, ws.MaskFrameInPlace(ws.NewTextFrame([]byte("foo"))))
+		// drain the close frame
+		_, _ = ws.ReadFrame(client)
+	}()
+	_, err := decoder.Read(make([]byte, 512))
+	assert.Equal(t, ErrTextFrameUnsupported, err)
+}
@@ -1,10 +1,17 @@
 package znet
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"github.com/ebar-go/ego/utils/runtime"
 	"github.com/ebar-go/ego/utils/structure"
+	"github.com/ebar-go/znet/acceptor"
+	"github.com/ebar-go/znet/codec"
 	uuid "github.com/satori/go.uuid"
 	"net"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // ConnectionHandler represents a connection handler
@@ -18,10 +25,35 @@ type Connection struct {
 	uuid string
 	// instance is the connection
 	instance net.Conn
+	// schema is the schema of the acceptor which accepted the connection
+	schema acceptor.Schema
 	// once make sure Close() is called only one times
 	once sync.Once
+	// mu protects the beforeCloseHooks and closed
+	mu sync.Mutex
+	// closed is true when Close() is called
+	closed bool
 	// beforeCloseHooks is a list of hooks that are called before the connection
 	beforeCloseHooks []func(connection *Connection)
+	// closeCode and closeReason are sent by the websocket close frame, see CloseWithStatus
+	closeCode   uint16
+	closeReason string
+	// writeMu make sure the messages are not interleaved
+	writeMu sync.Mutex
+	// outbound is the queue of messages waiting to be written
+	outbound *outbound
+	// lastActive is the unix nano time of the last received message
+	lastActive int64
+	// readTimeout and writeTimeout are the deadlines of a single read/write operation
+	readTimeout, writeTimeout time.Duration
+	// codec and packetOptions are used to encode and decode the messages,
+	// the codec may be negotiated by the connection, see SetContentType
+	codecMu       sync.RWMutex
+	codec         codec.Codec
+	contentType   string
+	packetOptions *codec.Options
+	// metrics records the outbound statistics
+	metrics *Metrics
 	// is a map of properties
 	property *structure.ConcurrentMap[string, any]
 }
@@ -38,14 +70,132 @@ func (conn *Connection) IP() string {
 // ID returns the uuid of the connection
 func (conn *Connection) ID() string { return conn.uuid }
 
-// Push send message to the connection
+// Schema returns the schema of the acceptor which accepted the connection
+func (conn *Connection) Schema() acceptor.Schema { return conn.schema }
+
+// TLSState returns the state of tls connection, returns nil if the connection is not tls
+func (conn *Connection) TLSState() *tls.ConnectionState {
+	c := conn.instance
+	for c != nil {
+		switch instance := c.(type) {
+		case *tls.Conn:
+			state := instance.ConnectionState()
+			return &state
+		case interface{ NetConn() net.Conn }:
+			// unwrap the decoder
+			c = instance.NetConn()
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// PeerCertificate returns the certificate presented by client, returns nil if not presented
+func (conn *Connection) PeerCertificate() *x509.Certificate {
+	state := conn.TLSState()
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	return state.PeerCertificates[0]
+}
+
+// CloseStatus returns the status code and reason of the websocket close frame sent or received,
+// the code is zero if the connection is not websocket or there is no close frame
+func (conn *Connection) CloseStatus() (code uint16, reason string) {
+	conn.mu.Lock()
+	code, reason = conn.closeCode, conn.closeReason
+	conn.mu.Unlock()
+	if code != 0 {
+		return
+	}
+	if instance, ok := conn.instance.(interface{ CloseStatus() (uint16, string) }); ok {
+		return instance.CloseStatus()
+	}
+	return 0, ""
+}
+
+// CloseWithStatus closes the connection, the websocket close frame is sent with the status code and reason
+// after the queued messages, it is the same as Close if the connection is not websocket
+func (conn *Connection) CloseWithStatus(code uint16, reason string) {
+	conn.mu.Lock()
+	if !conn.closed {
+		conn.closeCode, conn.closeReason = code, reason
+	}
+	conn.mu.Unlock()
+	conn.Close()
+}
+
+// Push send message to the connection through the outbound queue
 func (conn *Connection) Push(p []byte) {
-	_, _ = conn.Write(p)
+	_ = conn.enqueue(p)
 }
 
-// Write writes message to the connection
+// NewPacket returns an empty packet with the codec of connection
+func (conn *Connection) NewPacket() *codec.Packet {
+	return codec.NewPacketWithOptions(conn.Codec(), conn.packetOptions)
+}
+
+// Codec returns the codec of connection
+func (conn *Connection) Codec() codec.Codec {
+	conn.codecMu.RLock()
+	defer conn.codecMu.RUnlock()
+	return conn.codec
+}
+
+// ContentType returns the content type of the codec
+func (conn *Connection) ContentType() string {
+	conn.codecMu.RLock()
+	defer conn.codecMu.RUnlock()
+	return conn.contentType
+}
+
+// SetContentType switches the codec of connection, returns error if the content type is not registered
+func (conn *Connection) SetContentType(contentType string) error {
+	cc, err := codec.New(contentType)
+	if err != nil {
+		return err
+	}
+	conn.setCodec(contentType, cc)
+	return nil
+}
+
+func (conn *Connection) setCodec(contentType string, cc codec.Codec) {
+	conn.codecMu.Lock()
+	conn.codec, conn.contentType = cc, contentType
+	conn.codecMu.Unlock()
+}
+
+// Send encodes the payload with the codec of connection and sends it through the outbound queue
+func (conn *Connection) Send(action, seq int32, payload any) error {
+	msg, err := conn.NewPacket().EncodeWith(action, seq, payload)
+	if err != nil {
+		return err
+	}
+	return conn.enqueue(msg)
+}
+
+// Write writes message to the connection synchronously
 func (conn *Connection) Write(p []byte) (int, error) {
-	return conn.instance.Write(p)
+	var deadline time.Time
+	if conn.writeTimeout > 0 {
+		deadline = time.Now().Add(conn.writeTimeout)
+	}
+	return conn.writeBefore(p, deadline)
+}
+
+// writeBefore writes message to the connection synchronously before the deadline, zero means no deadline
+func (conn *Connection) writeBefore(p []byte, deadline time.Time) (int, error) {
+	conn.writeMu.Lock()
+	defer conn.writeMu.Unlock()
+	if !deadline.IsZero() {
+		_ = conn.instance.SetWriteDeadline(deadline)
+	}
+	n, err := conn.instance.Write(p)
+	if err == nil {
+		conn.metrics.send(n)
+	}
+	return n, err
 }
 
 // Read reads message from the connection
@@ -53,27 +203,116 @@ func (conn *Connection) Read(p []byte) (int, error) {
 	return conn.instance.Read(p)
 }
 
+// extendReadDeadline limits the duration of the next read operation
+func (conn *Connection) extendReadDeadline() {
+	if conn.readTimeout > 0 {
+		_ = conn.instance.SetReadDeadline(time.Now().Add(conn.readTimeout))
+	}
+}
+
+// LastActive returns the time of the last received message
+func (conn *Connection) LastActive() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&conn.lastActive))
+}
+
+// touch refresh the last active time
+func (conn *Connection) touch() {
+	atomic.StoreInt64(&conn.lastActive, time.Now().UnixNano())
+}
+
 // Close closes the connection
 func (conn *Connection) Close() {
 	conn.once.Do(func() {
-		for _, hook := range conn.beforeCloseHooks {
+		conn.mu.Lock()
+		conn.closed = true
+		hooks := conn.beforeCloseHooks
+		code, reason := conn.closeCode, conn.closeReason
+		conn.mu.Unlock()
+
+		for _, hook := range hooks {
 			hook(conn)
 		}
+
+		if instance, ok := conn.instance.(interface {
+			CloseWithStatus(uint16, string) error
+		}); ok && code != 0 {
+			_ = instance.CloseWithStatus(code, reason)
+			return
+		}
 		_ = conn.instance.Close()
 	})
 }
 
-// AddBeforeCloseHook adds a hook to the connection before closed
+// AddBeforeCloseHook adds a hook to the connection before closed,
+// the hooks will be invoked immediately if the connection is already closed
 func (conn *Connection) AddBeforeCloseHook(hooks ...func(conn *Connection)) {
-	conn.beforeCloseHooks = append(conn.beforeCloseHooks, hooks...)
+	conn.mu.Lock()
+	if !conn.closed {
+		conn.beforeCloseHooks = append(conn.beforeCloseHooks, hooks...)
+		conn.mu.Unlock()
+		return
+	}
+	conn.mu.Unlock()
+
+	for _, hook := range hooks {
+		hook(conn)
+	}
 }
 
-// NewConnection returns a new Connection instance
+// finish tells the decoder that the request has no response, like the quic request stream waiting for it
+func (conn *Connection) finish(p []byte) {
+	if finisher, ok := conn.instance.(codec.Finisher); ok {
+		finisher.Finish(p)
+	}
+}
+
+// enqueue push message to the outbound queue, write directly if the queue is not served
+func (conn *Connection) enqueue(p []byte) error {
+	if conn.outbound == nil {
+		_, err := conn.Write(p)
+		return err
+	}
+	return conn.outbound.offer(conn, p)
+}
+
+// tryEnqueue push message to the outbound queue without blocking, returns false if the queue is full
+func (conn *Connection) tryEnqueue(p []byte) bool {
+	if conn.outbound == nil {
+		_, err := conn.Write(p)
+		return err == nil
+	}
+	return conn.outbound.tryOffer(p)
+}
+
+// serveOutbound starts the writer which drains the outbound queue until the connection closed
+func (conn *Connection) serveOutbound(options ConnectionOptions) {
+	conn.outbound = newOutbound(options.OutboundQueueSize, options.OverflowPolicy)
+	conn.AddBeforeCloseHook(func(conn *Connection) {
+		conn.outbound.stop(conn, options.FlushTimeout)
+	})
+
+	go func() {
+		defer runtime.HandleCrash()
+		conn.outbound.drain(conn)
+	}()
+}
+
+// NewConnection returns a new Connection instance,
+// the properties of the conn are copied, like the request uri and headers of websocket upgrade request
 func NewConnection(conn net.Conn, fd int) *Connection {
-	return &Connection{
-		instance: conn,
-		fd:       fd,
-		uuid:     uuid.NewV4().String(),
-		property: structure.NewConcurrentMap[string, any](),
+	connection := &Connection{
+		instance:      conn,
+		fd:            fd,
+		uuid:          uuid.NewV4().String(),
+		property:      structure.NewConcurrentMap[string, any](),
+		codec:         codec.NewJsonCodec(),
+		contentType:   codec.ContentTypeJson,
+		packetOptions: codec.DefaultOptions(),
+	}
+	if instance, ok := conn.(interface{ Properties() map[string]string }); ok {
+		for key, value := range instance.Properties() {
+			connection.property.Set(key, value)
+		}
 	}
+	return connection
 }
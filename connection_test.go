@@ -1,6 +1,8 @@
 package znet
 
 import (
+	"github.com/ebar-go/znet/codec"
+	"github.com/gobwas/ws"
 	"github.com/stretchr/testify/assert"
 	"log"
 	"net"
@@ -45,3 +47,44 @@ func TestConnection_Push(t *testing.T) {
 	assert.Nil(t, err)
 	log.Println("receive:", string(p[:n]))
 }
+
+func TestConnection_Send(t *testing.T) {
+	connection := NewConnection(provideNetConn(), 1)
+	assert.NotNil(t, connection.Send(1, 1, make(chan int)))
+}
+
+func TestConnection_CloseWithStatus(t *testing.T) {
+	server, client := net.Pipe()
+	connection := NewConnection(codec.NewWebsocketDecoder(server), 1)
+
+	closed := make(chan ws.Frame, 1)
+	go func() {
+		frame, err := ws.ReadFrame(client)
+		assert.Nil(t, err)
+		closed <- frame
+		_ = client.Close()
+	}()
+
+	connection.CloseWithStatus(uint16(ws.StatusGoingAway), "bye")
+	frame := <-closed
+	assert.Equal(t, ws.OpClose, frame.Header.OpCode)
+	code, reason := ws.ParseCloseFrameData(frame.Payload)
+	assert.Equal(t, ws.StatusGoingAway, code)
+	assert.Equal(t, "bye", reason)
+
+	code2, reason2 := connection.CloseStatus()
+	assert.Equal(t, uint16(ws.StatusGoingAway), code2)
+	assert.Equal(t, "bye", reason2)
+}
+
+func TestConnection_SetContentType(t *testing.T) {
+	connection := NewConnection(provideNetConn(), 1)
+	assert.Equal(t, codec.ContentTypeJson, connection.ContentType())
+
+	assert.Nil(t, connection.SetContentType(codec.ContentTypeMsgpack))
+	assert.Equal(t, codec.ContentTypeMsgpack, connection.ContentType())
+	assert.IsType(t, codec.NewMsgpackCodec(), connection.Codec())
+
+	assert.NotNil(t, connection.SetContentType("xml"))
+	assert.Equal(t, codec.ContentTypeMsgpack, connection.ContentType())
+}
@@ -23,6 +23,8 @@ type Context struct {
 	conn   *Connection
 
 	packet *codec.Packet
+	// responded is true if the router has queued the response of request
+	responded bool
 }
 
 func (ctx *Context) Packet() *codec.Packet {
@@ -58,4 +60,5 @@ func (ctx *Context) reset(conn *Connection, packet *codec.Packet) {
 	ctx.index = 0
 	ctx.conn = conn
 	ctx.packet = packet
+	ctx.responded = false
 }
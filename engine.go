@@ -32,12 +32,13 @@ func (e *Engine) invoke(ctx *Context, index int8) {
 	e.handleChains[index](ctx)
 }
 
-// compute run invoke function with context
-func (e *Engine) compute(conn *Connection, packet *codec.Packet) {
+// compute run invoke function with context, returns true if the response is queued by router
+func (e *Engine) compute(conn *Connection, packet *codec.Packet) bool {
 	// acquire context from provider
 	ctx := e.contextProvider.Acquire()
 	ctx.reset(conn, packet)
 	defer e.contextProvider.Release(ctx)
 
 	e.invoke(ctx, 0)
+	return ctx.responded
 }
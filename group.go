@@ -0,0 +1,184 @@
+package znet
+
+import (
+	"github.com/ebar-go/ego/utils/structure"
+	"github.com/ebar-go/znet/codec"
+	"sync"
+)
+
+// Group represents a named set of connections, like chat room or game lobby
+type Group struct {
+	name    string
+	members *structure.ConcurrentMap[string, *Connection]
+}
+
+// Name returns the name of the group
+func (group *Group) Name() string { return group.name }
+
+// Len returns the number of members
+func (group *Group) Len() int {
+	n := 0
+	group.members.Iterator(func(_ string, _ *Connection) {
+		n++
+	})
+	return n
+}
+
+// Contains returns true if the connection is a member of the group
+func (group *Group) Contains(conn *Connection) bool {
+	_, ok := group.members.Get(conn.ID())
+	return ok
+}
+
+// Iterator invokes fn with every member of the group
+func (group *Group) Iterator(fn func(conn *Connection)) {
+	members := make([]*Connection, 0, 64)
+	group.members.Iterator(func(_ string, conn *Connection) {
+		members = append(members, conn)
+	})
+	for _, conn := range members {
+		fn(conn)
+	}
+}
+
+// Broadcast encodes the packet once and sends it to every member of the group,
+// use Send if the members negotiate different codecs
+func (group *Group) Broadcast(packet *codec.Packet) error {
+	msg, err := packet.Pack()
+	if err != nil {
+		return err
+	}
+
+	group.Iterator(func(conn *Connection) {
+		conn.Push(msg)
+	})
+	return nil
+}
+
+// Send encodes the payload once per content type of the members and sends it to every member of the group,
+// returns the first encoding error, the members of that content type are skipped
+func (group *Group) Send(action int32, payload any) (err error) {
+	messages := make(map[string][]byte)
+	group.Iterator(func(conn *Connection) {
+		contentType := conn.ContentType()
+		msg, ok := messages[contentType]
+		if !ok {
+			var lastErr error
+			if msg, lastErr = conn.NewPacket().EncodeWith(action, 0, payload); lastErr != nil && err == nil {
+				err = lastErr
+			}
+			messages[contentType] = msg
+		}
+		if msg != nil {
+			conn.Push(msg)
+		}
+	})
+	return
+}
+
+// GroupManager manage the groups, the connection will leave all groups automatically when closed
+type GroupManager struct {
+	mu     sync.Mutex
+	groups map[string]*Group
+	// joined is the group names of every connection
+	joined map[string]map[string]struct{}
+}
+
+// NewGroupManager returns a new GroupManager instance
+func NewGroupManager() *GroupManager {
+	return &GroupManager{
+		groups: make(map[string]*Group),
+		joined: make(map[string]map[string]struct{}),
+	}
+}
+
+// Get returns the group by name
+func (manager *GroupManager) Get(name string) (*Group, bool) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	group, ok := manager.groups[name]
+	return group, ok
+}
+
+// Len returns the number of groups
+func (manager *GroupManager) Len() int {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	return len(manager.groups)
+}
+
+// Join adds the connection to the group, the group will be created if not exist
+func (manager *GroupManager) Join(name string, conn *Connection) {
+	manager.mu.Lock()
+	group, ok := manager.groups[name]
+	if !ok {
+		group = &Group{name: name, members: structure.NewConcurrentMap[string, *Connection]()}
+		manager.groups[name] = group
+	}
+	group.members.Set(conn.ID(), conn)
+
+	names, joined := manager.joined[conn.ID()]
+	if !joined {
+		names = make(map[string]struct{})
+		manager.joined[conn.ID()] = names
+	}
+	names[name] = struct{}{}
+	manager.mu.Unlock()
+
+	// leave all groups before the connection closed, register only once for every connection
+	if !joined {
+		conn.AddBeforeCloseHook(manager.LeaveAll)
+	}
+}
+
+// Leave removes the connection from the group, the group will be removed when it is empty
+func (manager *GroupManager) Leave(name string, conn *Connection) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	manager.leave(name, conn)
+	if names, ok := manager.joined[conn.ID()]; ok {
+		delete(names, name)
+	}
+}
+
+// LeaveAll removes the connection from all the groups it joined
+func (manager *GroupManager) LeaveAll(conn *Connection) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	for name := range manager.joined[conn.ID()] {
+		manager.leave(name, conn)
+	}
+	delete(manager.joined, conn.ID())
+}
+
+// Broadcast sends the packet to every member of the group
+func (manager *GroupManager) Broadcast(name string, packet *codec.Packet) error {
+	group, ok := manager.Get(name)
+	if !ok {
+		return nil
+	}
+	return group.Broadcast(packet)
+}
+
+// Send encodes the payload by the codec of every member and sends it to the group
+func (manager *GroupManager) Send(name string, action int32, payload any) error {
+	group, ok := manager.Get(name)
+	if !ok {
+		return nil
+	}
+	return group.Send(action, payload)
+}
+
+// ==================private methods================
+func (manager *GroupManager) leave(name string, conn *Connection) {
+	group, ok := manager.groups[name]
+	if !ok {
+		return
+	}
+	group.members.Del(conn.ID())
+	if group.Len() == 0 {
+		delete(manager.groups, name)
+	}
+}
@@ -0,0 +1,91 @@
+package znet
+
+import (
+	"github.com/ebar-go/znet/codec"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"sync"
+	"testing"
+)
+
+func TestNewGroupManager(t *testing.T) {
+	manager := NewGroupManager()
+	assert.NotNil(t, manager)
+	assert.Equal(t, 0, manager.Len())
+}
+
+func TestGroupManager_JoinAndLeave(t *testing.T) {
+	manager := NewGroupManager()
+	foo, bar := NewConnection(nil, 1), NewConnection(nil, 2)
+
+	manager.Join("lobby", foo)
+	manager.Join("lobby", bar)
+	manager.Join("room", foo)
+
+	group, ok := manager.Get("lobby")
+	assert.True(t, ok)
+	assert.Equal(t, "lobby", group.Name())
+	assert.Equal(t, 2, group.Len())
+	assert.True(t, group.Contains(foo))
+	assert.Equal(t, 2, manager.Len())
+
+	manager.Leave("lobby", bar)
+	assert.False(t, group.Contains(bar))
+
+	// the group is removed when it is empty
+	manager.Leave("room", foo)
+	_, ok = manager.Get("room")
+	assert.False(t, ok)
+}
+
+func TestGroupManager_LeaveAll(t *testing.T) {
+	manager := NewGroupManager()
+	conn := NewConnection(nil, 1)
+
+	manager.Join("lobby", conn)
+	manager.Join("room", conn)
+	assert.Equal(t, 2, manager.Len())
+
+	manager.LeaveAll(conn)
+	assert.Equal(t, 0, manager.Len())
+}
+
+func TestGroup_Send(t *testing.T) {
+	manager := NewGroupManager()
+	decoders := map[string]codec.Codec{}
+	readers := map[string]net.Conn{}
+	for _, contentType := range []string{codec.ContentTypeJson, codec.ContentTypeCBOR} {
+		server, client := net.Pipe()
+		defer client.Close()
+		conn := NewConnection(server, -1)
+		assert.Nil(t, conn.SetContentType(contentType))
+		manager.Join("lobby", conn)
+		decoders[contentType], readers[contentType] = conn.Codec(), client
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- manager.Send("lobby", 1, map[string]string{"foo": "bar"})
+	}()
+
+	// the members are written in order, so each pipe must be read concurrently
+	wg := sync.WaitGroup{}
+	for contentType, reader := range readers {
+		wg.Add(1)
+		go func(contentType string, reader net.Conn) {
+			defer wg.Done()
+			buf := make([]byte, 512)
+			n, err := reader.Read(buf)
+			if !assert.Nil(t, err, contentType) {
+				return
+			}
+			packet := codec.NewPacket(decoders[contentType])
+			assert.Nil(t, packet.Unpack(buf[:n]))
+			payload := map[string]string{}
+			assert.Nil(t, packet.Unmarshal(&payload), contentType)
+			assert.Equal(t, "bar", payload["foo"])
+		}(contentType, reader)
+	}
+	wg.Wait()
+	assert.Nil(t, <-done)
+}
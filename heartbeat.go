@@ -0,0 +1,156 @@
+package znet
+
+import (
+	"sync"
+	"time"
+)
+
+// Heartbeat closes the connections which are idle past the timeout,
+// and answers the application-level ping packet without going through the Router.
+type Heartbeat struct {
+	options HeartbeatOptions
+	wheel   *timingWheel
+}
+
+// NewHeartbeat returns a new Heartbeat instance
+func NewHeartbeat(options HeartbeatOptions) *Heartbeat {
+	return &Heartbeat{
+		options: options,
+		wheel:   newTimingWheel(options.Tick, options.IdleTimeout),
+	}
+}
+
+// Enabled returns true if the idle detection is enabled
+func (heartbeat *Heartbeat) Enabled() bool {
+	return heartbeat.options.IdleTimeout > 0
+}
+
+// Track starts tracking the activity of connection until closed
+func (heartbeat *Heartbeat) Track(conn *Connection) {
+	if !heartbeat.Enabled() {
+		return
+	}
+	conn.touch()
+	heartbeat.wheel.add(conn, heartbeat.options.IdleTimeout)
+	conn.AddBeforeCloseHook(heartbeat.wheel.remove)
+}
+
+// Run runs the timing wheel until the stop signal closed
+func (heartbeat *Heartbeat) Run(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(heartbeat.wheel.tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case now := <-ticker.C:
+			heartbeat.check(now)
+		}
+	}
+}
+
+// ==================private methods================
+// check closes the expired connections and reschedules the active ones
+func (heartbeat *Heartbeat) check(now time.Time) {
+	for _, conn := range heartbeat.wheel.advance() {
+		idle := now.Sub(conn.LastActive())
+		if idle >= heartbeat.options.IdleTimeout {
+			conn.Close()
+			continue
+		}
+		heartbeat.wheel.add(conn, heartbeat.options.IdleTimeout-idle)
+	}
+}
+
+// handlePing answers the ping packet with pong packet, other packets are passed to the next handler
+func (heartbeat *Heartbeat) handlePing(ctx *Context) {
+	if heartbeat.options.PingAction == 0 || ctx.Packet().Action != heartbeat.options.PingAction {
+		ctx.Next()
+		return
+	}
+
+	pong := ctx.Conn().NewPacket()
+	pong.Action = heartbeat.options.PongAction
+	pong.Seq = ctx.Packet().Seq
+	msg, err := pong.Pack()
+	if err != nil {
+		return
+	}
+	ctx.Conn().Push(msg)
+}
+
+// timingWheel is a hashed wheel of connections, every slot is expired after a tick
+type timingWheel struct {
+	mu       sync.Mutex
+	tick     time.Duration
+	cursor   int
+	slots    []map[string]*Connection
+	position map[string]int // position is the slot index of every connection
+}
+
+func newTimingWheel(tick, timeout time.Duration) *timingWheel {
+	if tick <= 0 {
+		tick = time.Second
+	}
+	size := int(timeout/tick) + 2
+	slots := make([]map[string]*Connection, size)
+	for i := range slots {
+		slots[i] = make(map[string]*Connection)
+	}
+	return &timingWheel{
+		tick:     tick,
+		slots:    slots,
+		position: make(map[string]int),
+	}
+}
+
+// add puts the connection into the slot which will be expired after the delay
+func (wheel *timingWheel) add(conn *Connection, delay time.Duration) {
+	wheel.mu.Lock()
+	defer wheel.mu.Unlock()
+
+	steps := int((delay + wheel.tick - 1) / wheel.tick)
+	if steps < 1 {
+		steps = 1
+	} else if steps >= len(wheel.slots) {
+		steps = len(wheel.slots) - 1
+	}
+
+	if index, ok := wheel.position[conn.ID()]; ok {
+		delete(wheel.slots[index], conn.ID())
+	}
+	index := (wheel.cursor + steps) % len(wheel.slots)
+	wheel.slots[index][conn.ID()] = conn
+	wheel.position[conn.ID()] = index
+}
+
+// remove removes the connection from the wheel
+func (wheel *timingWheel) remove(conn *Connection) {
+	wheel.mu.Lock()
+	defer wheel.mu.Unlock()
+
+	if index, ok := wheel.position[conn.ID()]; ok {
+		delete(wheel.slots[index], conn.ID())
+		delete(wheel.position, conn.ID())
+	}
+}
+
+// advance moves the cursor forward and returns the connections of the expired slot
+func (wheel *timingWheel) advance() []*Connection {
+	wheel.mu.Lock()
+	defer wheel.mu.Unlock()
+
+	wheel.cursor = (wheel.cursor + 1) % len(wheel.slots)
+	slot := wheel.slots[wheel.cursor]
+	if len(slot) == 0 {
+		return nil
+	}
+
+	expired := make([]*Connection, 0, len(slot))
+	for id, conn := range slot {
+		expired = append(expired, conn)
+		delete(wheel.position, id)
+	}
+	wheel.slots[wheel.cursor] = make(map[string]*Connection)
+	return expired
+}
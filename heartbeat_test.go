@@ -0,0 +1,50 @@
+package znet
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNewHeartbeat(t *testing.T) {
+	heartbeat := NewHeartbeat(defaultHeartbeatOptions())
+	assert.NotNil(t, heartbeat)
+	assert.False(t, heartbeat.Enabled())
+}
+
+func TestHeartbeat_Check(t *testing.T) {
+	heartbeat := NewHeartbeat(HeartbeatOptions{IdleTimeout: time.Second, Tick: 100 * time.Millisecond})
+	server, client := net.Pipe()
+	defer client.Close()
+
+	closed := make(chan struct{})
+	conn := NewConnection(server, 1)
+	conn.AddBeforeCloseHook(func(conn *Connection) {
+		close(closed)
+	})
+	heartbeat.Track(conn)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go heartbeat.Run(stop)
+
+	select {
+	case <-closed:
+	case <-time.After(3 * time.Second):
+		t.Fatal("idle connection is not closed")
+	}
+}
+
+func TestTimingWheel_AddAndRemove(t *testing.T) {
+	wheel := newTimingWheel(time.Second, 3*time.Second)
+	conn := NewConnection(nil, 1)
+
+	wheel.add(conn, time.Second)
+	assert.Equal(t, []*Connection{conn}, wheel.advance())
+	assert.Nil(t, wheel.advance())
+
+	wheel.add(conn, time.Second)
+	wheel.remove(conn)
+	assert.Nil(t, wheel.advance())
+}
@@ -0,0 +1,88 @@
+package znet
+
+import (
+	"github.com/ebar-go/ego/utils/structure"
+	"github.com/ebar-go/znet/acceptor"
+)
+
+// ConnectionManager manage all the active connections, indexed by the uuid of connection
+type ConnectionManager struct {
+	container *structure.ConcurrentMap[string, *Connection]
+}
+
+// NewConnectionManager returns a new ConnectionManager instance
+func NewConnectionManager() *ConnectionManager {
+	return &ConnectionManager{
+		container: structure.NewConcurrentMap[string, *Connection](),
+	}
+}
+
+// Register adds the connection to the manager
+func (manager *ConnectionManager) Register(conn *Connection) {
+	manager.container.Set(conn.ID(), conn)
+}
+
+// Unregister removes the connection from the manager
+func (manager *ConnectionManager) Unregister(conn *Connection) {
+	manager.container.Del(conn.ID())
+}
+
+// Get returns the connection by id
+func (manager *ConnectionManager) Get(id string) (*Connection, bool) {
+	return manager.container.Get(id)
+}
+
+// Iterator invokes fn with every active connection,
+// it iterates a snapshot so fn is allowed to close the connection
+func (manager *ConnectionManager) Iterator(fn func(conn *Connection)) {
+	for _, conn := range manager.snapshot() {
+		fn(conn)
+	}
+}
+
+// Len returns the number of active connections
+func (manager *ConnectionManager) Len() int {
+	return manager.count(func(conn *Connection) bool { return true })
+}
+
+// CountBySchema returns the number of active connections accepted by the schema
+func (manager *ConnectionManager) CountBySchema(schema acceptor.Schema) int {
+	return manager.count(func(conn *Connection) bool {
+		return conn.Schema() == schema
+	})
+}
+
+// CountByProtocol returns the number of active connections of the protocol, e.g. tcp,ws,quic
+func (manager *ConnectionManager) CountByProtocol(protocol string) int {
+	return manager.count(func(conn *Connection) bool {
+		return conn.Schema().Protocol == protocol
+	})
+}
+
+// Kick closes the connection by id, returns false if the connection is not found
+func (manager *ConnectionManager) Kick(id string) bool {
+	conn, ok := manager.Get(id)
+	if !ok {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// ==================private methods================
+func (manager *ConnectionManager) snapshot() []*Connection {
+	connections := make([]*Connection, 0, 64)
+	manager.container.Iterator(func(_ string, conn *Connection) {
+		connections = append(connections, conn)
+	})
+	return connections
+}
+
+func (manager *ConnectionManager) count(filter func(conn *Connection) bool) (n int) {
+	manager.container.Iterator(func(_ string, conn *Connection) {
+		if filter(conn) {
+			n++
+		}
+	})
+	return
+}
@@ -0,0 +1,54 @@
+package znet
+
+import (
+	"github.com/ebar-go/znet/acceptor"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewConnectionManager(t *testing.T) {
+	manager := NewConnectionManager()
+	assert.NotNil(t, manager)
+	assert.Equal(t, 0, manager.Len())
+}
+
+func TestConnectionManager_RegisterAndUnregister(t *testing.T) {
+	manager := NewConnectionManager()
+	conn := NewConnection(nil, 1)
+	manager.Register(conn)
+
+	item, ok := manager.Get(conn.ID())
+	assert.True(t, ok)
+	assert.Equal(t, conn, item)
+	assert.Equal(t, 1, manager.Len())
+
+	manager.Unregister(conn)
+	_, ok = manager.Get(conn.ID())
+	assert.False(t, ok)
+	assert.Equal(t, 0, manager.Len())
+}
+
+func TestConnectionManager_Count(t *testing.T) {
+	manager := NewConnectionManager()
+	tcp, ws := acceptor.NewTCPSchema(":8081"), acceptor.NewWebSocketSchema(":8082")
+	for _, schema := range []acceptor.Schema{tcp, tcp, ws} {
+		conn := NewConnection(nil, 1)
+		conn.schema = schema
+		manager.Register(conn)
+	}
+
+	assert.Equal(t, 2, manager.CountBySchema(tcp))
+	assert.Equal(t, 1, manager.CountByProtocol(acceptor.WEBSOCKET))
+	assert.Equal(t, 0, manager.CountByProtocol(acceptor.QUIC))
+
+	n := 0
+	manager.Iterator(func(conn *Connection) {
+		n++
+	})
+	assert.Equal(t, 3, n)
+}
+
+func TestConnectionManager_Kick(t *testing.T) {
+	manager := NewConnectionManager()
+	assert.False(t, manager.Kick("not-exist"))
+}
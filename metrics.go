@@ -0,0 +1,108 @@
+package znet
+
+import (
+	"fmt"
+	"github.com/rcrowley/go-metrics"
+	"time"
+)
+
+// Metrics records the statistics of network into the go-metrics registry,
+// it is safe to call the methods of nil Metrics.
+type Metrics struct {
+	registry metrics.Registry
+
+	packetsIn, packetsOut metrics.Meter
+	bytesIn, bytesOut     metrics.Meter
+	decodeFailures        metrics.Counter
+}
+
+// NewMetrics returns a new Metrics instance
+func NewMetrics(registry metrics.Registry) *Metrics {
+	return &Metrics{
+		registry:       registry,
+		packetsIn:      metrics.GetOrRegisterMeter("packets.in", registry),
+		packetsOut:     metrics.GetOrRegisterMeter("packets.out", registry),
+		bytesIn:        metrics.GetOrRegisterMeter("bytes.in", registry),
+		bytesOut:       metrics.GetOrRegisterMeter("bytes.out", registry),
+		decodeFailures: metrics.GetOrRegisterCounter("decode.failures", registry),
+	}
+}
+
+// Registry returns the registry which can be plugged into exporters
+func (m *Metrics) Registry() metrics.Registry {
+	if m == nil {
+		return nil
+	}
+	return m.registry
+}
+
+// ==================private methods================
+// trackConnection records the accepted connection until closed
+func (m *Metrics) trackConnection(conn *Connection) {
+	if m == nil {
+		return
+	}
+	schema := conn.Schema().String()
+	metrics.GetOrRegisterCounter(fmt.Sprintf("connections.accepted[%s]", schema), m.registry).Inc(1)
+	active := metrics.GetOrRegisterCounter(fmt.Sprintf("connections.active[%s]", schema), m.registry)
+	active.Inc(1)
+
+	conn.AddBeforeCloseHook(func(conn *Connection) {
+		active.Dec(1)
+		metrics.GetOrRegisterCounter(fmt.Sprintf("connections.closed[%s]", schema), m.registry).Inc(1)
+	})
+}
+
+func (m *Metrics) receive(n int) {
+	if m == nil {
+		return
+	}
+	m.packetsIn.Mark(1)
+	m.bytesIn.Mark(int64(n))
+}
+
+func (m *Metrics) send(n int) {
+	if m == nil {
+		return
+	}
+	m.packetsOut.Mark(1)
+	m.bytesOut.Mark(int64(n))
+}
+
+func (m *Metrics) decodeFailed() {
+	if m == nil {
+		return
+	}
+	m.decodeFailures.Inc(1)
+}
+
+// handled records the latency and error of the action handler
+func (m *Metrics) handled(action int32, begin time.Time, err error) {
+	if m == nil {
+		return
+	}
+	metrics.GetOrRegisterTimer(fmt.Sprintf("router.latency[%d]", action), m.registry).UpdateSince(begin)
+	if err != nil {
+		metrics.GetOrRegisterCounter(fmt.Sprintf("router.errors[%d]", action), m.registry).Inc(1)
+	}
+}
+
+// watchQueue registers the gauge of worker-pool queue depth,
+// it fails if the registry is shared with another running Network, whose gauge is kept
+func (m *Metrics) watchQueue(depth func() int64) error {
+	if m == nil {
+		return nil
+	}
+	if err := m.registry.Register("worker.queue", metrics.NewFunctionalGauge(depth)); err != nil {
+		return fmt.Errorf("Metrics: %v", err)
+	}
+	return nil
+}
+
+// unwatchQueue unregisters the gauge of worker-pool queue depth
+func (m *Metrics) unwatchQueue() {
+	if m == nil {
+		return
+	}
+	m.registry.Unregister("worker.queue")
+}
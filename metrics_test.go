@@ -0,0 +1,70 @@
+package znet
+
+import (
+	"errors"
+	"github.com/ebar-go/znet/acceptor"
+	"github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNewMetrics(t *testing.T) {
+	registry := metrics.NewRegistry()
+	m := NewMetrics(registry)
+	assert.Equal(t, registry, m.Registry())
+}
+
+func TestMetrics_TrackConnection(t *testing.T) {
+	m := NewMetrics(metrics.NewRegistry())
+	server, client := net.Pipe()
+	defer client.Close()
+
+	conn := NewConnection(server, 1)
+	conn.schema = acceptor.NewTCPSchema(":8081")
+	m.trackConnection(conn)
+
+	active := m.Registry().Get("connections.active[tcp://:8081]").(metrics.Counter)
+	assert.Equal(t, int64(1), active.Count())
+
+	conn.Close()
+	assert.Equal(t, int64(0), active.Count())
+	closed := m.Registry().Get("connections.closed[tcp://:8081]").(metrics.Counter)
+	assert.Equal(t, int64(1), closed.Count())
+}
+
+func TestMetrics_Handled(t *testing.T) {
+	m := NewMetrics(metrics.NewRegistry())
+	m.handled(1, time.Now(), nil)
+	m.handled(1, time.Now(), errors.New("foo"))
+
+	latency := m.Registry().Get("router.latency[1]").(metrics.Timer)
+	assert.Equal(t, int64(2), latency.Count())
+	failures := m.Registry().Get("router.errors[1]").(metrics.Counter)
+	assert.Equal(t, int64(1), failures.Count())
+}
+
+func TestMetrics_WatchQueue(t *testing.T) {
+	registry := metrics.NewRegistry()
+	m := NewMetrics(registry)
+	assert.Nil(t, m.watchQueue(func() int64 { return 1 }))
+
+	// the gauge of another instance sharing the registry is not replaced
+	assert.NotNil(t, NewMetrics(registry).watchQueue(func() int64 { return 2 }))
+	assert.Equal(t, int64(1), registry.Get("worker.queue").(metrics.Gauge).Value())
+
+	m.unwatchQueue()
+	assert.Nil(t, registry.Get("worker.queue"))
+	assert.Nil(t, NewMetrics(registry).watchQueue(func() int64 { return 2 }))
+}
+
+func TestMetrics_Nil(t *testing.T) {
+	var m *Metrics
+	assert.Nil(t, m.watchQueue(nil))
+	m.unwatchQueue()
+	m.receive(1)
+	m.send(1)
+	m.decodeFailed()
+	m.handled(1, time.Now(), nil)
+}
@@ -1,16 +1,24 @@
 package znet
 
 import (
+	"crypto/tls"
 	"errors"
+	"fmt"
 	"github.com/ebar-go/ego/utils/pool"
 	"github.com/ebar-go/znet/acceptor"
 	"github.com/ebar-go/znet/codec"
+	"github.com/lucas-clemente/quic-go"
+	"github.com/rcrowley/go-metrics"
+	"strings"
 	"time"
 )
 
 const (
-	ContentTypeJson  = "json"
-	ContentTypeProto = "protobuf"
+	ContentTypeJson    = codec.ContentTypeJson
+	ContentTypeProto   = codec.ContentTypeProto
+	ContentTypeMsgpack = codec.ContentTypeMsgpack
+	ContentTypeCBOR    = codec.ContentTypeCBOR
+	ContentTypeRaw     = codec.ContentTypeRaw
 )
 
 type ErrorHandler func(ctx *Context, err error)
@@ -31,21 +39,79 @@ type Options struct {
 	// Middlewares is a lot of callback functions that are called when the connection send new message
 	Middlewares []HandleFunc
 
+	// MetricsRegistry is the registry of the built-in metrics, default is a new registry.
+	// The Networks sharing it add up the counters, but only one of them runs with the worker.queue gauge
+	MetricsRegistry metrics.Registry
+
 	Reactor ReactorOptions
 
 	Thread ThreadOptions
 
+	Connection ConnectionOptions
+
+	Heartbeat HeartbeatOptions
+
+	Shutdown ShutdownOptions
+
 	Acceptor acceptor.Options
 }
 
+// ShutdownOptions represents the options for the graceful shutdown
+type ShutdownOptions struct {
+	// Timeout is the max duration waiting for the in-flight requests, default is 10 seconds
+	Timeout time.Duration
+
+	// GoingAwayAction is the action of the packet sent to every connection before closed,
+	// default is zero which means disabled
+	GoingAwayAction int32
+}
+
+// HeartbeatOptions represents the options for the idle detection
+type HeartbeatOptions struct {
+	// IdleTimeout closes the connection which has not received any message over the duration,
+	// default is zero which means disabled
+	IdleTimeout time.Duration
+
+	// Tick is the precision of the idle detection, default is one second
+	Tick time.Duration
+
+	// PingAction is the action of ping packet which is answered by the framework with PongAction,
+	// default is zero which means disabled
+	PingAction int32
+
+	// PongAction is the action of pong packet
+	PongAction int32
+}
+
+// ConnectionOptions represents the options for the connection
+type ConnectionOptions struct {
+	// OutboundQueueSize is the capacity of the outbound queue of every connection, default is 128
+	OutboundQueueSize int
+
+	// OverflowPolicy decides what to do when the outbound queue is full, default is OverflowBlock
+	OverflowPolicy OverflowPolicy
+
+	// FlushTimeout is the max duration of writing the queued messages when the connection is closed,
+	// the remaining messages are dropped after it, default is 1 second
+	FlushTimeout time.Duration
+}
+
 type ThreadOptions struct {
 	// MaxReadBufferSize is the size of the max read buffer, default is 512
 	MaxReadBufferSize int
 
 	packetLengthSize int
 
+	// ContentType is the name of the codec registered to the codec package, default is json
 	ContentType string
 
+	// NegotiateAction is the action of the handshake packet which switches the codec of connection
+	// by the content type in body, the content type in use is replied. Default is zero which means disabled
+	NegotiateAction int32
+
+	// Packet is the header layout of the packets, default is codec.DefaultOptions()
+	Packet *codec.Options
+
 	WorkerPool *pool.Options
 }
 
@@ -57,10 +123,11 @@ func (options ThreadOptions) NewWorkerPool() pool.GoroutinePool {
 	})
 }
 
-func (options ThreadOptions) NewCodec() (cc codec.Codec) {
-	cc = codec.NewJsonCodec()
-	if options.ContentType == ContentTypeProto {
-		cc = codec.NewProtoCodec()
+// NewCodec returns the codec of ContentType, returns the json codec if it is not registered
+func (options ThreadOptions) NewCodec() codec.Codec {
+	cc, err := codec.New(options.ContentType)
+	if err != nil {
+		return codec.NewJsonCodec()
 	}
 	return cc
 }
@@ -110,6 +177,14 @@ func (options *Options) NewRouter() *Router {
 	return NewRouter()
 }
 
+func (options *Options) NewMetrics() *Metrics {
+	return NewMetrics(options.MetricsRegistry)
+}
+
+func (options *Options) NewHeartbeat() *Heartbeat {
+	return NewHeartbeat(options.Heartbeat)
+}
+
 // Validate validates the options parameter
 func (options *Options) Validate() error {
 	if options.Reactor.EpollBufferSize <= 0 {
@@ -128,6 +203,37 @@ func (options *Options) Validate() error {
 		return errors.New("Reactor.ThreadQueueCapacity must be greater than zero")
 	}
 
+	if options.Connection.OutboundQueueSize <= 0 {
+		return errors.New("Connection.OutboundQueueSize must be greater than zero")
+	}
+
+	if _, err := codec.New(options.Thread.ContentType); err != nil {
+		return errors.New("Thread.ContentType: " + err.Error())
+	}
+
+	if options.Thread.Packet == nil {
+		return errors.New("Thread.Packet is required")
+	}
+	if err := options.Thread.Packet.Validate(); err != nil {
+		return errors.New("Thread.Packet: " + err.Error())
+	}
+
+	// the actions must fit the width of header, otherwise they are truncated and never matched
+	actions := []struct {
+		name   string
+		action int32
+	}{
+		{"Heartbeat.PingAction", options.Heartbeat.PingAction},
+		{"Heartbeat.PongAction", options.Heartbeat.PongAction},
+		{"Shutdown.GoingAwayAction", options.Shutdown.GoingAwayAction},
+		{"Thread.NegotiateAction", options.Thread.NegotiateAction},
+	}
+	for _, item := range actions {
+		if err := options.Thread.Packet.CheckAction(item.action); err != nil {
+			return fmt.Errorf("%s: %v", item.name, err)
+		}
+	}
+
 	return nil
 }
 
@@ -136,6 +242,9 @@ func completeOptions(setters ...Option) *Options {
 	for _, setter := range setters {
 		setter(options)
 	}
+	if options.Acceptor.Packet == nil {
+		options.Acceptor.Packet = options.Thread.Packet
+	}
 	return options
 }
 
@@ -143,12 +252,16 @@ type Option func(options *Options)
 
 func defaultOptions() *Options {
 	return &Options{
-		Debug:    false,
-		OnOpen:   func(conn *Connection) {},
-		OnClose:  func(conn *Connection) {},
-		Reactor:  defaultReactorOptions(),
-		Thread:   defaultThreadOptions(),
-		Acceptor: acceptor.DefaultOptions(),
+		Debug:           false,
+		OnOpen:          func(conn *Connection) {},
+		OnClose:         func(conn *Connection) {},
+		MetricsRegistry: metrics.NewRegistry(),
+		Reactor:         defaultReactorOptions(),
+		Thread:          defaultThreadOptions(),
+		Connection:      defaultConnectionOptions(),
+		Heartbeat:       defaultHeartbeatOptions(),
+		Shutdown:        defaultShutdownOptions(),
+		Acceptor:        acceptor.DefaultOptions(),
 	}
 }
 
@@ -165,6 +278,7 @@ func defaultThreadOptions() ThreadOptions {
 		MaxReadBufferSize: 512,
 		packetLengthSize:  4,
 		ContentType:       ContentTypeJson, // default is json
+		Packet:            codec.DefaultOptions(),
 		WorkerPool: &pool.Options{
 			Max:     10000,
 			Idle:    100,
@@ -173,6 +287,26 @@ func defaultThreadOptions() ThreadOptions {
 	}
 }
 
+func defaultConnectionOptions() ConnectionOptions {
+	return ConnectionOptions{
+		OutboundQueueSize: 128,
+		OverflowPolicy:    OverflowBlock,
+		FlushTimeout:      time.Second,
+	}
+}
+
+func defaultHeartbeatOptions() HeartbeatOptions {
+	return HeartbeatOptions{
+		Tick: time.Second,
+	}
+}
+
+func defaultShutdownOptions() ShutdownOptions {
+	return ShutdownOptions{
+		Timeout: time.Second * 10,
+	}
+}
+
 // WithDebug enables debugging
 func WithDebug() Option {
 	return func(options *Options) {
@@ -201,9 +335,178 @@ func WithErrorHandler(handler ErrorHandler) Option {
 	}
 }
 
+// WithTLSConfig sets the tls config of the tls, wss and quic acceptors
+func WithTLSConfig(config *tls.Config) Option {
+	return func(options *Options) {
+		options.Acceptor.TLSConfig = config
+	}
+}
+
+// WithQUICConfig sets the transport config of the quic acceptor, like idle timeout, keep-alive and max streams
+func WithQUICConfig(config *quic.Config) Option {
+	return func(options *Options) {
+		options.Acceptor.QUIC.Config = config
+	}
+}
+
+// WithQUICProtocols sets the ALPN list of the quic acceptor
+func WithQUICProtocols(protos ...string) Option {
+	return func(options *Options) {
+		options.Acceptor.QUIC.NextProtos = protos
+	}
+}
+
+// WithQUIC0RTT accepts the 0-RTT data of the quic connections
+func WithQUIC0RTT() Option {
+	return func(options *Options) {
+		options.Acceptor.QUIC.Allow0RTT = true
+	}
+}
+
+// WithQUICStreamPerRequest answers every quic request on the stream where it came from
+func WithQUICStreamPerRequest() Option {
+	return func(options *Options) {
+		options.Acceptor.QUIC.StreamPerRequest = true
+	}
+}
+
+// WithQUICSelfSigned makes the quic acceptor generate a self-signed certificate if no tls config is set,
+// it is only for development
+func WithQUICSelfSigned() Option {
+	return func(options *Options) {
+		options.Acceptor.QUIC.SelfSigned = true
+	}
+}
+
+// WithWebsocketText accepts the websocket text frames of valid UTF-8, see codec.WebsocketOptions.AcceptText
+func WithWebsocketText() Option {
+	return func(options *Options) {
+		options.Acceptor.Websocket.AcceptText = true
+	}
+}
+
+// WithWebsocketPath restricts the path of websocket upgrade request
+func WithWebsocketPath(path string) Option {
+	return func(options *Options) {
+		options.Acceptor.Handshake.Path = path
+	}
+}
+
+// WithWebsocketOrigins rejects the websocket upgrade request whose origin is not in the list
+func WithWebsocketOrigins(origins ...string) Option {
+	return func(options *Options) {
+		options.Acceptor.Handshake.CheckOrigin = func(origin string) bool {
+			for _, item := range origins {
+				if strings.EqualFold(item, origin) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+}
+
+// WithWebsocketHeaders copies the headers of websocket upgrade request to the connection properties,
+// the property key is acceptor.PropertyHeader(name)
+func WithWebsocketHeaders(names ...string) Option {
+	return func(options *Options) {
+		options.Acceptor.Handshake.Headers = append(options.Acceptor.Handshake.Headers, names...)
+	}
+}
+
+// WithWebsocketHandshake sets the hook of websocket upgrade request, return error to reject it,
+// see acceptor.NewHandshakeError
+func WithWebsocketHandshake(hook func(handshake *acceptor.Handshake) error) Option {
+	return func(options *Options) {
+		options.Acceptor.Handshake.OnHandshake = hook
+	}
+}
+
+// WithPacketOptions sets the header layout of the packets, the clients must use the same layout
+func WithPacketOptions(packet *codec.Options) Option {
+	return func(options *Options) {
+		options.Thread.Packet = packet
+	}
+}
+
+// WithCompression compresses the body of packets larger than threshold, the flags field is appended to the header
+// if it is not defined, so the clients must enable it too. Use it after WithPacketOptions
+func WithCompression(compressor codec.Compressor, threshold int) Option {
+	return func(options *Options) {
+		options.Thread.Packet = options.Thread.Packet.WithCompression(compressor, threshold)
+	}
+}
+
+// WithCodecNegotiation enables every connection to choose its codec by the content type,
+// through the websocket subprotocol or the handshake packet of the action, zero action means websocket only.
+// The Acceptor.Handshake.Protocol set before is kept, a subprotocol is accepted only if both of them accept it
+func WithCodecNegotiation(action int32) Option {
+	return func(options *Options) {
+		options.Thread.NegotiateAction = action
+		previous := options.Acceptor.Handshake.Protocol
+		options.Acceptor.Handshake.Protocol = func(protocol string) bool {
+			if previous != nil && !previous(protocol) {
+				return false
+			}
+			_, err := codec.New(protocol)
+			return err == nil
+		}
+	}
+}
+
 // WithContentType sets the content type
 func WithContentType(contentType string) Option {
 	return func(options *Options) {
 		options.Thread.ContentType = contentType
 	}
 }
+
+// WithOverflowPolicy sets the overflow policy of the outbound queue
+func WithOverflowPolicy(policy OverflowPolicy) Option {
+	return func(options *Options) {
+		options.Connection.OverflowPolicy = policy
+	}
+}
+
+// WithFlushTimeout sets the max duration of writing the queued messages when the connection is closed
+func WithFlushTimeout(timeout time.Duration) Option {
+	return func(options *Options) {
+		options.Connection.FlushTimeout = timeout
+	}
+}
+
+// WithIdleTimeout closes the connection which is idle past the timeout
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(options *Options) {
+		options.Heartbeat.IdleTimeout = timeout
+	}
+}
+
+// WithPingPong enables the framework to answer the ping packet with pong packet
+func WithPingPong(ping, pong int32) Option {
+	return func(options *Options) {
+		options.Heartbeat.PingAction = ping
+		options.Heartbeat.PongAction = pong
+	}
+}
+
+// WithShutdownTimeout sets the max duration waiting for the in-flight requests when shutdown
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(options *Options) {
+		options.Shutdown.Timeout = timeout
+	}
+}
+
+// WithGoingAway sends the packet of action to every connection before closed when shutdown
+func WithGoingAway(action int32) Option {
+	return func(options *Options) {
+		options.Shutdown.GoingAwayAction = action
+	}
+}
+
+// WithMetricsRegistry sets the registry of the built-in metrics
+func WithMetricsRegistry(registry metrics.Registry) Option {
+	return func(options *Options) {
+		options.MetricsRegistry = registry
+	}
+}
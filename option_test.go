@@ -1,7 +1,10 @@
 package znet
 
 import (
+	"encoding/binary"
+	"github.com/ebar-go/znet/codec"
 	"github.com/stretchr/testify/assert"
+	"strings"
 	"testing"
 )
 
@@ -11,7 +14,31 @@ func TestOptions_NewMainReactor(t *testing.T) {
 }
 
 func TestOptions_Validate(t *testing.T) {
+	options := defaultOptions()
+	assert.Nil(t, options.Validate())
+
+	WithContentType("xml")(options)
+	assert.EqualError(t, options.Validate(), "Thread.ContentType: unsupported content type: xml")
+	WithContentType(ContentTypeMsgpack)(options)
+	assert.Nil(t, options.Validate())
+
+	WithPacketOptions(&codec.Options{ByteOrder: binary.LittleEndian, ActionSize: 3, SeqSize: 4})(options)
+	assert.NotNil(t, options.Validate())
 
+	WithPacketOptions(&codec.Options{
+		ByteOrder:  binary.LittleEndian,
+		ActionSize: 4,
+		SeqSize:    4,
+		Fields:     []codec.Field{{Name: "version", Size: 1}, {Name: "version", Size: 1}},
+	})(options)
+	assert.NotNil(t, options.Validate())
+
+	// the actions must fit the width of header
+	options = defaultOptions()
+	WithPingPong(40000, 40001)(options)
+	assert.EqualError(t, options.Validate(), "Heartbeat.PingAction: "+codec.ErrActionOverflow.Error())
+	WithPacketOptions(&codec.Options{ByteOrder: binary.BigEndian, ActionSize: 4, SeqSize: 2})(options)
+	assert.Nil(t, options.Validate())
 }
 
 func Test_defaultOptions(t *testing.T) {
@@ -23,3 +50,68 @@ func Test_defaultReactorOptions(t *testing.T) {
 	options := defaultReactorOptions()
 	assert.NotNil(t, options)
 }
+
+func TestWithCompression(t *testing.T) {
+	options := defaultOptions()
+	WithCompression(codec.NewZstdCompressor(), 64)(options)
+	assert.Nil(t, options.Validate())
+	assert.Equal(t, []codec.Field{{Name: codec.FlagsField, Size: 1}}, options.Thread.Packet.Fields)
+	assert.Empty(t, codec.DefaultOptions().Fields)
+
+	body := strings.Repeat("history", 100)
+	for _, data := range []string{"small", body} {
+		msg, err := codec.NewPacketWithOptions(codec.NewJsonCodec(), options.Thread.Packet).EncodeWith(1, 1, data)
+		assert.Nil(t, err)
+		if data == body {
+			assert.Less(t, len(msg), len(body))
+		}
+
+		// the packet is decompressed by the id of flags whatever the compressor is
+		packet := codec.NewPacketWithOptions(codec.NewJsonCodec(), codec.DefaultOptions().WithCompression(nil, 0))
+		assert.Nil(t, packet.Unpack(msg))
+		var actual string
+		assert.Nil(t, packet.Unmarshal(&actual))
+		assert.Equal(t, data, actual)
+	}
+}
+
+func TestWithCodecNegotiation(t *testing.T) {
+	options := defaultOptions()
+	WithCodecNegotiation(0)(options)
+	assert.True(t, options.Acceptor.Handshake.Protocol(ContentTypeJson))
+	assert.True(t, options.Acceptor.Handshake.Protocol(ContentTypeCBOR))
+	assert.False(t, options.Acceptor.Handshake.Protocol("unknown"))
+
+	// the protocol callback set before is chained
+	options = defaultOptions()
+	options.Acceptor.Handshake.Protocol = func(protocol string) bool {
+		return protocol != ContentTypeCBOR
+	}
+	WithCodecNegotiation(0)(options)
+	assert.True(t, options.Acceptor.Handshake.Protocol(ContentTypeJson))
+	assert.False(t, options.Acceptor.Handshake.Protocol(ContentTypeCBOR))
+	assert.False(t, options.Acceptor.Handshake.Protocol("unknown"))
+}
+
+func TestThreadOptions_NewCodec(t *testing.T) {
+	request := map[string]any{"name": "foo", "tags": []any{"a", "b"}}
+	for _, contentType := range []string{ContentTypeJson, ContentTypeMsgpack, ContentTypeCBOR} {
+		cc := ThreadOptions{ContentType: contentType}.NewCodec()
+		p, err := cc.Marshal(request)
+		assert.Nil(t, err)
+		actual := map[string]any{}
+		assert.Nil(t, cc.Unmarshal(p, &actual))
+		assert.Equal(t, request, actual, contentType)
+	}
+
+	raw := ThreadOptions{ContentType: ContentTypeRaw}.NewCodec()
+	p, err := raw.Marshal("body")
+	assert.Nil(t, err)
+	var body []byte
+	assert.Nil(t, raw.Unmarshal(p, &body))
+	assert.Equal(t, "body", string(body))
+	_, err = raw.Marshal(request)
+	assert.Equal(t, codec.ErrInvalidRawData, err)
+
+	assert.IsType(t, codec.NewJsonCodec(), ThreadOptions{ContentType: "xml"}.NewCodec())
+}
@@ -0,0 +1,174 @@
+package znet
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// OverflowPolicy decides what to do when the outbound queue of connection is full
+type OverflowPolicy int
+
+const (
+	// OverflowBlock blocks the caller until the queue has free space
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest drops the oldest message in the queue
+	OverflowDropOldest
+	// OverflowDropNewest drops the message which is being pushed
+	OverflowDropNewest
+	// OverflowClose closes the connection
+	OverflowClose
+)
+
+var (
+	ErrOutboundQueueFull = errors.New("outbound queue is full")
+	ErrConnectionClosed  = errors.New("connection is closed")
+)
+
+// outbound is a bounded queue of messages waiting to be written to the connection
+type outbound struct {
+	queue  chan []byte
+	policy OverflowPolicy
+	once   sync.Once
+	done   chan struct{}
+	exited chan struct{}
+	// deadline limits the flush after stopped
+	deadline time.Time
+}
+
+func newOutbound(size int, policy OverflowPolicy) *outbound {
+	return &outbound{
+		queue:  make(chan []byte, size),
+		policy: policy,
+		done:   make(chan struct{}),
+		exited: make(chan struct{}),
+	}
+}
+
+// offer push the message to the queue according to the overflow policy
+func (o *outbound) offer(conn *Connection, p []byte) error {
+	select {
+	case <-o.done:
+		return ErrConnectionClosed
+	default:
+	}
+
+	select {
+	case o.queue <- p:
+		return nil
+	default:
+	}
+
+	switch o.policy {
+	case OverflowDropOldest:
+		for {
+			// drop the oldest one and try again
+			select {
+			case <-o.queue:
+			default:
+			}
+			select {
+			case o.queue <- p:
+				return nil
+			default:
+			}
+		}
+	case OverflowDropNewest:
+		return ErrOutboundQueueFull
+	case OverflowClose:
+		// the peer is too slow to receive the queued messages, close without flushing
+		o.close(0)
+		conn.Close()
+		return ErrOutboundQueueFull
+	default:
+		select {
+		case o.queue <- p:
+			return nil
+		case <-o.done:
+			return ErrConnectionClosed
+		}
+	}
+}
+
+// tryOffer pushes the message to the queue without blocking, returns false if the queue is full or stopped
+func (o *outbound) tryOffer(p []byte) bool {
+	select {
+	case <-o.done:
+		return false
+	default:
+	}
+
+	select {
+	case o.queue <- p:
+		return true
+	default:
+		return false
+	}
+}
+
+// drain writes the messages in the queue to the connection until stopped
+func (o *outbound) drain(conn *Connection) {
+	defer close(o.exited)
+	for {
+		select {
+		case <-o.done:
+			o.flush(conn)
+			return
+		case p := <-o.queue:
+			if err := o.write(conn, p); err != nil {
+				// stop() is waiting for the writer exited, so close asynchronously
+				go conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// flush writes the remaining messages in the queue before the deadline
+func (o *outbound) flush(conn *Connection) {
+	for {
+		select {
+		case p := <-o.queue:
+			if err := o.write(conn, p); err != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// write writes the message with the write deadline of connection, or the flush deadline once stopped
+func (o *outbound) write(conn *Connection, p []byte) (err error) {
+	select {
+	case <-o.done:
+		_, err = conn.writeBefore(p, o.deadline)
+	default:
+		_, err = conn.Write(p)
+	}
+	return
+}
+
+// close stops draining the queue, the remaining messages are flushed within timeout
+func (o *outbound) close(timeout time.Duration) {
+	o.once.Do(func() {
+		o.deadline = time.Now().Add(timeout)
+		close(o.done)
+	})
+}
+
+// stop stops draining the queue and waits for the remaining messages written no more than timeout,
+// the write blocked by the peer which stops reading is interrupted when timed out
+func (o *outbound) stop(conn *Connection, timeout time.Duration) {
+	o.close(timeout)
+
+	timer := time.NewTimer(time.Until(o.deadline))
+	defer timer.Stop()
+	select {
+	case <-o.exited:
+		return
+	case <-timer.C:
+	}
+	_ = conn.instance.SetWriteDeadline(time.Now())
+	<-o.exited
+}
@@ -0,0 +1,92 @@
+package znet
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestOutbound_OfferDropNewest(t *testing.T) {
+	o := newOutbound(1, OverflowDropNewest)
+	assert.Nil(t, o.offer(nil, []byte("foo")))
+	assert.Equal(t, ErrOutboundQueueFull, o.offer(nil, []byte("bar")))
+	assert.Equal(t, []byte("foo"), <-o.queue)
+}
+
+func TestOutbound_OfferDropOldest(t *testing.T) {
+	o := newOutbound(1, OverflowDropOldest)
+	assert.Nil(t, o.offer(nil, []byte("foo")))
+	assert.Nil(t, o.offer(nil, []byte("bar")))
+	assert.Equal(t, []byte("bar"), <-o.queue)
+}
+
+func TestOutbound_OfferAfterStop(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	o := newOutbound(1, OverflowBlock)
+	conn := NewConnection(server, 1)
+	go o.drain(conn)
+	o.stop(conn, time.Second)
+	assert.Equal(t, ErrConnectionClosed, o.offer(nil, []byte("foo")))
+}
+
+func TestOutbound_StopTimeout(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	// the peer never reads, the writer is blocked
+	o := newOutbound(2, OverflowBlock)
+	conn := NewConnection(server, 1)
+	assert.Nil(t, o.offer(conn, []byte("foo")))
+	assert.Nil(t, o.offer(conn, []byte("bar")))
+	go o.drain(conn)
+
+	start := time.Now()
+	o.stop(conn, 50*time.Millisecond)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestOutbound_OverflowClose(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	conn := NewConnection(server, 1)
+	options := defaultConnectionOptions()
+	options.OutboundQueueSize = 1
+	options.OverflowPolicy = OverflowClose
+	options.FlushTimeout = time.Minute
+	conn.serveOutbound(options)
+
+	// the queue is closed without waiting for the flush
+	start := time.Now()
+	var err error
+	for err == nil {
+		err = conn.enqueue([]byte("foo"))
+	}
+	assert.Equal(t, ErrOutboundQueueFull, err)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestOutbound_TryOffer(t *testing.T) {
+	o := newOutbound(1, OverflowBlock)
+	assert.True(t, o.tryOffer([]byte("foo")))
+	assert.False(t, o.tryOffer([]byte("bar")))
+	o.close(0)
+	assert.False(t, o.tryOffer([]byte("bar")))
+}
+
+func TestConnection_serveOutbound(t *testing.T) {
+	server, client := net.Pipe()
+	conn := NewConnection(server, 1)
+	conn.serveOutbound(defaultConnectionOptions())
+	defer conn.Close()
+
+	conn.Push([]byte("foo"))
+
+	p := make([]byte, 512)
+	n, err := client.Read(p)
+	assert.Nil(t, err)
+	assert.Equal(t, "foo", string(p[:n]))
+}
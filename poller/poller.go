@@ -9,6 +9,7 @@ type Poller interface {
 	Add(fd int) error
 	Remove(fd int) error
 	Wait() ([]int, error)
+	Close() error
 }
 
 // SocketFD get socket connection fd
@@ -0,0 +1,21 @@
+package znet
+
+// Notifier is a generic server-push helper which binds the action to the payload type
+type Notifier[Payload any] struct {
+	action int32
+}
+
+// NewNotifier returns a new Notifier for the action
+func NewNotifier[Payload any](action int32) Notifier[Payload] {
+	return Notifier[Payload]{action: action}
+}
+
+// Action returns the action of notifier
+func (notifier Notifier[Payload]) Action() int32 {
+	return notifier.action
+}
+
+// Notify encodes the payload with the codec of server and sends it to the connection
+func (notifier Notifier[Payload]) Notify(conn *Connection, payload *Payload) error {
+	return conn.Send(notifier.action, 0, payload)
+}
@@ -0,0 +1,34 @@
+package znet
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+func TestNewNotifier(t *testing.T) {
+	type Message struct {
+		Content string `json:"content"`
+	}
+	notifier := NewNotifier[Message](101)
+	assert.Equal(t, int32(101), notifier.Action())
+
+	server, client := net.Pipe()
+	conn := NewConnection(server, 1)
+	conn.serveOutbound(defaultConnectionOptions())
+	defer conn.Close()
+
+	assert.Nil(t, notifier.Notify(conn, &Message{Content: "foo"}))
+
+	p := make([]byte, 512)
+	n, err := client.Read(p)
+	assert.Nil(t, err)
+
+	packet := conn.NewPacket()
+	assert.Nil(t, packet.Unpack(p[:n]))
+	assert.Equal(t, int32(101), packet.Action)
+
+	message := new(Message)
+	assert.Nil(t, packet.Unmarshal(message))
+	assert.Equal(t, "foo", message.Content)
+}
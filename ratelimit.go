@@ -0,0 +1,226 @@
+package znet
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitKey returns the key of the token bucket for the request
+type RateLimitKey func(ctx *Context) string
+
+// RateLimitPolicy decides what to do when the request is limited
+type RateLimitPolicy int
+
+const (
+	// RateLimitDrop drops the request silently
+	RateLimitDrop RateLimitPolicy = iota
+	// RateLimitReply replies an error packet with the ReplyAction
+	RateLimitReply
+	// RateLimitClose closes the connection after MaxViolations times
+	RateLimitClose
+)
+
+// KeyByConnection limits the requests of every connection
+func KeyByConnection(ctx *Context) string {
+	return ctx.Conn().ID()
+}
+
+// KeyByIP limits the requests of every remote ip
+func KeyByIP(ctx *Context) string {
+	host, _, err := net.SplitHostPort(ctx.Conn().IP())
+	if err != nil {
+		return ctx.Conn().IP()
+	}
+	return host
+}
+
+// KeyByAction limits the requests of every action
+func KeyByAction(ctx *Context) string {
+	return strconv.Itoa(int(ctx.Packet().Action))
+}
+
+// RateLimitOptions represents the options for the rate limiter
+type RateLimitOptions struct {
+	// Rate is the number of tokens refilled per second, must be greater than zero
+	Rate float64
+
+	// Burst is the capacity of the bucket, default is Rate rounded up
+	Burst int
+
+	// Key returns the key of the bucket, default is KeyByConnection
+	Key RateLimitKey
+
+	// Policy decides what to do when the request is limited, default is RateLimitDrop
+	Policy RateLimitPolicy
+
+	// ReplyAction is the action of the error packet, used by RateLimitReply
+	ReplyAction int32
+
+	// ReplyBody returns the body of the error packet which is encoded by the codec of connection,
+	// default is RateLimitError which can't be encoded by the protobuf codec, so return a proto.Message for it
+	ReplyBody func(ctx *Context) any
+
+	// MaxViolations is the number of limited requests which closes the connection, used by RateLimitClose,
+	// default is one which closes the connection at the first limited request
+	MaxViolations int
+
+	// Expiration removes the bucket which is idle past the duration, default is one minute
+	Expiration time.Duration
+}
+
+// RateLimitError is the body of the error packet
+type RateLimitError struct {
+	Action int32  `json:"action"`
+	Error  string `json:"error"`
+}
+
+// Validate validates the options
+func (options RateLimitOptions) Validate() error {
+	if options.Rate <= 0 {
+		return errors.New("Rate must be greater than zero")
+	}
+	if options.Burst < 0 {
+		return errors.New("Burst must not be negative")
+	}
+	if options.MaxViolations < 0 {
+		return errors.New("MaxViolations must not be negative")
+	}
+	return nil
+}
+
+// RateLimit returns a middleware limiting the requests with token bucket, it panics if the options are invalid
+func RateLimit(options RateLimitOptions) HandleFunc {
+	if err := options.Validate(); err != nil {
+		panic("znet: invalid RateLimitOptions: " + err.Error())
+	}
+	limiter := newRateLimiter(options)
+	return limiter.handle
+}
+
+func defaultRateLimitReply(ctx *Context) any {
+	return RateLimitError{
+		Action: ctx.Packet().Action,
+		Error:  "rate limit exceeded",
+	}
+}
+
+type rateLimiter struct {
+	options RateLimitOptions
+	// violationKey is the property key of the violation times, unique per limiter
+	// so that the limiters of the same connection count separately
+	violationKey string
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+func newRateLimiter(options RateLimitOptions) *rateLimiter {
+	if options.Key == nil {
+		options.Key = KeyByConnection
+	}
+	if options.Expiration <= 0 {
+		options.Expiration = time.Minute
+	}
+	if options.Burst == 0 {
+		options.Burst = int(math.Ceil(options.Rate))
+	}
+	if options.ReplyBody == nil {
+		options.ReplyBody = defaultRateLimitReply
+	}
+	if options.MaxViolations == 0 {
+		options.MaxViolations = 1
+	}
+	limiter := &rateLimiter{
+		options:   options,
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+	limiter.violationKey = fmt.Sprintf("znet.rateLimitViolations.%p", limiter)
+	return limiter
+}
+
+func (limiter *rateLimiter) handle(ctx *Context) {
+	if limiter.allow(limiter.options.Key(ctx), time.Now()) {
+		ctx.Next()
+		return
+	}
+
+	switch limiter.options.Policy {
+	case RateLimitReply:
+		body := limiter.options.ReplyBody(ctx)
+		if err := ctx.Conn().Send(limiter.options.ReplyAction, ctx.Packet().Seq, body); err != nil {
+			log.Printf("[%s] reply rate limit error failed: %v\n", ctx.Conn().ID(), err)
+		}
+	case RateLimitClose:
+		if limiter.violate(ctx.Conn()) >= limiter.options.MaxViolations {
+			ctx.Conn().Close()
+		}
+	}
+}
+
+// allow takes a token from the bucket of key
+func (limiter *rateLimiter) allow(key string, now time.Time) bool {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	limiter.sweep(now)
+	bucket, ok := limiter.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(limiter.options.Burst), updatedAt: now}
+		limiter.buckets[key] = bucket
+	}
+	return bucket.take(limiter.options.Rate, limiter.options.Burst, now)
+}
+
+// violate increases the violation times of the connection
+func (limiter *rateLimiter) violate(conn *Connection) int {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	times := 1
+	if val, ok := conn.Property().Get(limiter.violationKey); ok {
+		times += val.(int)
+	}
+	conn.Property().Set(limiter.violationKey, times)
+	return times
+}
+
+// sweep removes the expired buckets
+func (limiter *rateLimiter) sweep(now time.Time) {
+	if now.Sub(limiter.lastSweep) < limiter.options.Expiration {
+		return
+	}
+	limiter.lastSweep = now
+	for key, bucket := range limiter.buckets {
+		if now.Sub(bucket.updatedAt) >= limiter.options.Expiration {
+			delete(limiter.buckets, key)
+		}
+	}
+}
+
+// tokenBucket is refilled with rate tokens per second
+type tokenBucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+func (bucket *tokenBucket) take(rate float64, burst int, now time.Time) bool {
+	bucket.tokens += now.Sub(bucket.updatedAt).Seconds() * rate
+	if bucket.tokens > float64(burst) {
+		bucket.tokens = float64(burst)
+	}
+	bucket.updatedAt = now
+
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
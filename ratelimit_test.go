@@ -0,0 +1,99 @@
+package znet
+
+import (
+	"github.com/ebar-go/znet/codec"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_Allow(t *testing.T) {
+	limiter := newRateLimiter(RateLimitOptions{Rate: 1, Burst: 2})
+	now := time.Now()
+
+	assert.True(t, limiter.allow("foo", now))
+	assert.True(t, limiter.allow("foo", now))
+	assert.False(t, limiter.allow("foo", now))
+	assert.True(t, limiter.allow("bar", now))
+
+	// refilled after one second
+	assert.True(t, limiter.allow("foo", now.Add(time.Second)))
+}
+
+func TestRateLimiter_Sweep(t *testing.T) {
+	limiter := newRateLimiter(RateLimitOptions{Rate: 1, Burst: 1, Expiration: time.Second})
+	now := time.Now()
+	limiter.allow("foo", now)
+	assert.Len(t, limiter.buckets, 1)
+
+	limiter.allow("bar", now.Add(2*time.Second))
+	assert.Len(t, limiter.buckets, 1)
+}
+
+func TestRateLimit_Close(t *testing.T) {
+	handler := RateLimit(RateLimitOptions{Rate: 0.001, Burst: 1, Policy: RateLimitClose, MaxViolations: 2})
+	server, client := net.Pipe()
+	defer client.Close()
+
+	closed := false
+	conn := NewConnection(server, 1)
+	conn.AddBeforeCloseHook(func(conn *Connection) {
+		closed = true
+	})
+
+	ctx := &Context{engine: NewEngine()}
+	ctx.reset(conn, codec.NewPacket(codec.NewJsonCodec()))
+	handler(ctx)
+	handler(ctx)
+	assert.False(t, closed)
+
+	handler(ctx)
+	assert.True(t, closed)
+}
+
+func TestRateLimit_CloseSeparately(t *testing.T) {
+	options := RateLimitOptions{Rate: 0.001, Burst: 1, Policy: RateLimitClose, MaxViolations: 2}
+	byConnection, byAction := RateLimit(options), RateLimit(options)
+	server, client := net.Pipe()
+	defer client.Close()
+
+	closed := false
+	conn := NewConnection(server, 1)
+	conn.AddBeforeCloseHook(func(conn *Connection) {
+		closed = true
+	})
+
+	// each limiter counts its own violations
+	ctx := &Context{engine: NewEngine()}
+	ctx.reset(conn, codec.NewPacket(codec.NewJsonCodec()))
+	byConnection(ctx)
+	byConnection(ctx)
+	byAction(ctx)
+	byAction(ctx)
+	assert.False(t, closed)
+
+	byAction(ctx)
+	assert.True(t, closed)
+}
+
+func TestRateLimitOptions_Validate(t *testing.T) {
+	assert.NotNil(t, RateLimitOptions{}.Validate())
+	assert.NotNil(t, RateLimitOptions{Rate: 1, Burst: -1}.Validate())
+	assert.NotNil(t, RateLimitOptions{Rate: 1, MaxViolations: -1}.Validate())
+	assert.Nil(t, RateLimitOptions{Rate: 1}.Validate())
+	assert.Panics(t, func() {
+		RateLimit(RateLimitOptions{Burst: 1})
+	})
+
+	// the burst is the rate rounded up by default
+	limiter := newRateLimiter(RateLimitOptions{Rate: 1.5})
+	assert.Equal(t, 2, limiter.options.Burst)
+	now := time.Now()
+	assert.True(t, limiter.allow("foo", now))
+	assert.True(t, limiter.allow("foo", now))
+	assert.False(t, limiter.allow("foo", now))
+
+	// the connection is closed at the first violation by default
+	assert.Equal(t, 1, limiter.options.MaxViolations)
+}
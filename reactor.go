@@ -2,16 +2,17 @@ package znet
 
 import (
 	"github.com/ebar-go/ego/utils/runtime"
+	"github.com/ebar-go/znet/acceptor"
 	"github.com/ebar-go/znet/poller"
 	"log"
-	"math/rand"
 	"net"
 )
 
 // Reactor represents the epoll model for listen connections.
 type Reactor struct {
-	poll poller.Poller // use to listen active connections
-	sub  SubReactor    // manage connections
+	poll        poller.Poller      // use to listen active connections
+	sub         SubReactor         // manage connections
+	connections *ConnectionManager // index connections by uuid
 }
 
 // NewReactor return a new main reactor instance
@@ -22,8 +23,9 @@ func NewReactor(options ReactorOptions) (reactor *Reactor, err error) {
 	}
 
 	reactor = &Reactor{
-		poll: poll,
-		sub:  options.NewSubReactor(),
+		poll:        poll,
+		sub:         options.NewSubReactor(),
+		connections: NewConnectionManager(),
 	}
 
 	return
@@ -39,13 +41,21 @@ func (reactor *Reactor) Run(stopCh <-chan struct{}, onRequest ConnectionHandler)
 	}()
 
 	pollerSignal := make(chan struct{})
-	defer close(pollerSignal)
+	pollerDone := make(chan struct{})
 	go func() {
 		defer runtime.HandleCrash()
+		defer close(pollerDone)
 		reactor.listenPoller(pollerSignal)
 	}()
 
-	runtime.WaitClose(stopCh)
+	runtime.WaitClose(stopCh, func() {
+		// close the epoll fd after the poller stopped waiting
+		close(pollerSignal)
+		<-pollerDone
+		if err := reactor.poll.Close(); err != nil {
+			log.Println("unable to close poller:", err)
+		}
+	})
 }
 
 // ===================== private methods =================
@@ -83,10 +93,11 @@ func (reactor *Reactor) wrapHandler(handler ConnectionHandler) func(active int)
 }
 
 // initializeConnection this callback will be invoked when the connection is established
-func (reactor *Reactor) initializeConnection(onOpen, onClose ConnectionHandler) func(conn net.Conn) {
+func (reactor *Reactor) initializeConnection(schema acceptor.Schema, onOpen, onClose ConnectionHandler) func(conn net.Conn) {
 	return func(conn net.Conn) {
 		// create instance of Connection
 		connection := NewConnection(conn, poller.SocketFD(conn))
+		connection.schema = schema
 		if err := reactor.poll.Add(connection.fd); err != nil {
 			connection.Close()
 			log.Println("poll.Add failed: ", connection.fd, err)
@@ -96,6 +107,7 @@ func (reactor *Reactor) initializeConnection(onOpen, onClose ConnectionHandler)
 		onOpen(connection)
 
 		reactor.sub.RegisterConnection(connection)
+		reactor.connections.Register(connection)
 
 		// those callback functions will be invoked before connection.Close()
 		connection.AddBeforeCloseHook(
@@ -107,42 +119,36 @@ func (reactor *Reactor) initializeConnection(onOpen, onClose ConnectionHandler)
 			},
 			// unregister connection from sub reactor
 			reactor.sub.UnregisterConnection,
+			// unregister connection from connection manager
+			reactor.connections.Unregister,
 		)
 	}
 
 }
 
-func (reactor *Reactor) initializeUnSupportedReactorConnection(onOpen, onClose, onReceive ConnectionHandler) func(conn net.Conn) {
+// initializeUnSupportedReactorConnection this callback will be invoked when the connection which can't be managed by epoll is established,
+// onServe is invoked in a new goroutine to read the requests until the connection is closed
+func (reactor *Reactor) initializeUnSupportedReactorConnection(schema acceptor.Schema, onOpen, onClose, onServe ConnectionHandler) func(conn net.Conn) {
 	return func(conn net.Conn) {
-		// create instance of Connection
-		connection := NewConnection(conn, rand.Intn(100000))
+		// create instance of Connection, the fd is meaningless because it is not polled by epoll
+		connection := NewConnection(conn, -1)
+		connection.schema = schema
 
 		onOpen(connection)
 
-		reactor.sub.RegisterConnection(connection)
+		reactor.connections.Register(connection)
 
-		done := make(chan struct{})
 		// those callback functions will be invoked before connection.Close()
 		connection.AddBeforeCloseHook(
 			// trigger disconnect callback
 			onClose,
-			// unregister connection from sub reactor
-			reactor.sub.UnregisterConnection,
-			func(conn *Connection) {
-				close(done)
-			},
+			// unregister connection from connection manager
+			reactor.connections.Unregister,
 		)
 
-		go func(cc *Connection) {
-			for {
-				bytes := make([]byte, 512)
-				n, err := cc.Read(bytes)
-				if err != nil {
-					return
-				}
-				log.Println("receive:", cc.IP(), bytes[:n])
-				cc.Write(bytes[:n])
-			}
-		}(connection)
+		go func() {
+			defer runtime.HandleCrash()
+			onServe(connection)
+		}()
 	}
 }
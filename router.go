@@ -1,8 +1,10 @@
 package znet
 
 import (
+	"fmt"
 	"github.com/ebar-go/ego/utils/structure"
-	"log"
+	"github.com/ebar-go/znet/codec"
+	"time"
 )
 
 // Handler is a handler for operation
@@ -24,19 +26,20 @@ func StandardHandler[Request, Response any](action Action[Request, Response]) Ha
 
 // Router represents router instance
 type Router struct {
-	handlers        *structure.ConcurrentMap[int16, Handler]
+	handlers        *structure.ConcurrentMap[int32, Handler]
 	notFoundHandler HandleFunc
+	metrics         *Metrics
 }
 
 func NewRouter() *Router {
 	return &Router{
-		handlers:        structure.NewConcurrentMap[int16, Handler](),
+		handlers:        structure.NewConcurrentMap[int32, Handler](),
 		notFoundHandler: nil,
 	}
 }
 
 // Route register handler for action
-func (router *Router) Route(action int16, handler Handler) *Router {
+func (router *Router) Route(action int32, handler Handler) *Router {
 	router.handlers.Set(action, handler)
 	return router
 }
@@ -57,7 +60,9 @@ func (router *Router) handleRequest(onError func(ctx *Context, err error)) Handl
 			return
 		}
 
+		begin := time.Now()
 		response, err := handler(ctx)
+		router.metrics.handled(ctx.Packet().Action, begin, err)
 		if err != nil {
 			onError(ctx, err)
 			return
@@ -69,13 +74,25 @@ func (router *Router) handleRequest(onError func(ctx *Context, err error)) Handl
 			return
 		}
 
-		n, err := ctx.Conn().Write(msg)
-		log.Println(n, err)
-
+		if err = ctx.Conn().enqueue(msg); err != nil {
+			onError(ctx, err)
+			return
+		}
+		ctx.responded = true
 	}
 
 }
 
+// validate checks the actions of handlers fit the width of header
+func (router *Router) validate(options *codec.Options) (err error) {
+	router.handlers.Iterator(func(action int32, _ Handler) {
+		if err == nil && options.CheckAction(action) != nil {
+			err = fmt.Errorf("Router: action %d: %v", action, codec.ErrActionOverflow)
+		}
+	})
+	return
+}
+
 func (router *Router) triggerNotFoundEvent(ctx *Context) {
 	if router.notFoundHandler != nil {
 		router.notFoundHandler(ctx)
@@ -1,6 +1,8 @@
 package znet
 
 import (
+	"encoding/binary"
+	"github.com/ebar-go/znet/codec"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -20,3 +22,13 @@ func TestStandardHandler(t *testing.T) {
 	assert.NotNil(t, handler)
 
 }
+
+func TestRouter_Validate(t *testing.T) {
+	router := NewRouter()
+	router.Route(1, func(ctx *Context) (any, error) { return nil, nil })
+	assert.Nil(t, router.validate(codec.DefaultOptions()))
+
+	router.Route(70000, func(ctx *Context) (any, error) { return nil, nil })
+	assert.EqualError(t, router.validate(codec.DefaultOptions()), "Router: action 70000: "+codec.ErrActionOverflow.Error())
+	assert.Nil(t, router.validate(&codec.Options{ByteOrder: binary.BigEndian, ActionSize: 4, SeqSize: 2}))
+}
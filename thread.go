@@ -3,25 +3,41 @@ package znet
 import (
 	"github.com/ebar-go/ego/utils/pool"
 	"github.com/ebar-go/ego/utils/runtime"
+	"github.com/ebar-go/znet/acceptor"
 	"github.com/ebar-go/znet/codec"
 	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Thread represents context manager
 type Thread struct {
-	options ThreadOptions
-	codec   codec.Codec
-	worker  pool.GoroutinePool
-	engine  *Engine
+	options       ThreadOptions
+	codec         codec.Codec
+	packetOptions *codec.Options
+	worker        pool.GoroutinePool
+	engine        *Engine
+	metrics       *Metrics
+	// pending is the number of requests waiting for the worker
+	pending int64
+
+	// mu protects draining and the increment of inflight
+	mu       sync.RWMutex
+	draining bool
+	// inflight is the number of scheduled requests which are not completed
+	inflight sync.WaitGroup
 }
 
 // NewThread returns a new Thread instance
 func NewThread(options ThreadOptions) *Thread {
 	return &Thread{
-		options: options,
-		codec:   options.NewCodec(),
-		worker:  options.NewWorkerPool(),
-		engine:  NewEngine(),
+		options:       options,
+		codec:         options.NewCodec(),
+		packetOptions: options.Packet,
+		worker:        options.NewWorkerPool(),
+		engine:        NewEngine(),
 	}
 }
 
@@ -32,33 +48,166 @@ func (thread *Thread) Use(handlers ...HandleFunc) {
 
 // HandleRequest handle new request for connection
 func (thread *Thread) HandleRequest(conn *Connection) {
+	// the connection is readable, so the message should arrive in time
+	conn.extendReadDeadline()
+	drainer, ok := conn.instance.(codec.Drainer)
+	if !ok {
+		thread.handleRequest(conn)
+		return
+	}
+
+	// the edge-triggered epoll notifies once for the data arrived together, so handle all the complete frames
+	err := drainer.Drain(thread.options.MaxReadBufferSize, func() error {
+		if thread.handleRequest(conn) {
+			return nil
+		}
+		return net.ErrClosed
+	})
+	if err != nil && err != net.ErrClosed {
+		log.Printf("[%s] read failed: %v\n", conn.ID(), err)
+		conn.Close()
+	}
+}
+
+// ServeConnection reads and handles the requests of connection until it is closed,
+// it is used by the connections which are not managed by epoll.
+// The read deadline is not extended here because the read waits for the next message,
+// so the idle connections are closed by the heartbeat only
+func (thread *Thread) ServeConnection(conn *Connection) {
+	for thread.handleRequest(conn) {
+	}
+}
+
+// handleRequest reads one request from the connection and schedules it,
+// returns false if the connection is closed because of read failure
+func (thread *Thread) handleRequest(conn *Connection) bool {
 	// read message from connection
 	var (
 		n      = 0
 		bytes  = pool.GetByte(thread.options.MaxReadBufferSize)
-		packet = codec.NewPacket(thread.codec)
+		packet = codec.NewPacketWithOptions(conn.Codec(), thread.packetOptions)
 	)
 
 	err := runtime.Call(func() (lastErr error) {
 		n, lastErr = conn.Read(bytes)
 		return
-	}, func() error {
-		return packet.Unpack(bytes[:n])
+	}, func() (lastErr error) {
+		if n == 0 {
+			return
+		}
+		if lastErr = packet.Unpack(bytes[:n]); lastErr != nil {
+			thread.metrics.decodeFailed()
+		}
+		return
 	})
 
+	if err == acceptor.ErrDatagramTooLarge {
+		// only the datagram is dropped, the session of udp is still readable
+		log.Printf("[%s] read failed: %v\n", conn.ID(), err)
+		thread.metrics.decodeFailed()
+		pool.PutByte(bytes)
+		return true
+	}
 	if err != nil {
 		log.Printf("[%s] read failed: %v\n", conn.ID(), err)
 		// put back immediately when decode failed
 		pool.PutByte(bytes)
 		conn.Close()
-		return
+		return false
+	}
+	conn.touch()
+	if n == 0 {
+		// nothing to handle, like the websocket control frame
+		pool.PutByte(bytes)
+		return true
+	}
+	thread.metrics.receive(n)
+
+	if thread.options.NegotiateAction != 0 && packet.Action == thread.options.NegotiateAction {
+		// switch the codec before reading the next request
+		thread.negotiate(conn, packet)
+		pool.PutByte(bytes)
+		return true
+	}
+
+	thread.mu.RLock()
+	if thread.draining {
+		// discard new requests when shutting down
+		thread.mu.RUnlock()
+		pool.PutByte(bytes)
+		return true
 	}
+	thread.inflight.Add(1)
+	thread.mu.RUnlock()
 
 	// compute
+	atomic.AddInt64(&thread.pending, 1)
 	thread.worker.Schedule(func() {
+		atomic.AddInt64(&thread.pending, -1)
 		defer runtime.HandleCrash()
+		defer thread.inflight.Done()
 		defer pool.PutByte(bytes)
 
-		thread.engine.compute(conn, packet)
+		if !thread.engine.compute(conn, packet) {
+			// no response is coming, like the error, the unknown action or the ping
+			conn.finish(bytes[:n])
+		}
 	})
+	return true
+}
+
+// QueueDepth returns the number of requests waiting for the worker
+func (thread *Thread) QueueDepth() int64 {
+	return atomic.LoadInt64(&thread.pending)
+}
+
+// prepare binds the codec of thread to the connection, so that it can push messages by itself,
+// the codec negotiated by the websocket subprotocol is preferred
+func (thread *Thread) prepare(conn *Connection) {
+	conn.setCodec(thread.options.ContentType, thread.codec)
+	conn.packetOptions = thread.packetOptions
+	if protocol, ok := conn.Property().Get(acceptor.PropertyProtocol); ok {
+		if err := conn.SetContentType(protocol.(string)); err != nil {
+			log.Printf("[%s] negotiate failed: %v\n", conn.ID(), err)
+		}
+	}
+}
+
+// negotiate switches the codec of connection by the content type in body, replies the content type in use
+func (thread *Thread) negotiate(conn *Connection, packet *codec.Packet) {
+	if err := conn.SetContentType(string(packet.Body)); err != nil {
+		log.Printf("[%s] negotiate failed: %v\n", conn.ID(), err)
+	}
+	packet.Body = []byte(conn.ContentType())
+	msg, err := packet.Pack()
+	if err != nil {
+		return
+	}
+	conn.Push(msg)
+}
+
+// Drain stops scheduling new requests and waits for the in-flight requests completed,
+// returns false if the timeout is reached
+func (thread *Thread) Drain(timeout time.Duration) bool {
+	thread.mu.Lock()
+	thread.draining = true
+	thread.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		thread.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Stop stops the worker pool
+func (thread *Thread) Stop() {
+	thread.worker.Stop()
 }
@@ -1,8 +1,17 @@
 package znet
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"github.com/ebar-go/znet/acceptor"
+	"github.com/ebar-go/znet/codec"
+	"github.com/gobwas/ws"
+	"github.com/rcrowley/go-metrics"
 	"github.com/stretchr/testify/assert"
+	"net"
 	"testing"
+	"time"
 )
 
 func TestThread(t *testing.T) {
@@ -11,5 +20,328 @@ func TestThread(t *testing.T) {
 }
 
 func TestThread_UseAndHandleRequest(t *testing.T) {
-	
+	instance := NewThread(defaultThreadOptions())
+	defer instance.Stop()
+
+	received := make(chan string, 10)
+	instance.Use(func(ctx *Context) {
+		var request string
+		assert.Nil(t, ctx.Bind(&request))
+		received <- request
+	})
+
+	server, client := tcpPair(t)
+	defer client.Close()
+	conn := NewConnection(codec.NewLengthFieldBasedFromDecoder(server, 4), -1)
+	instance.prepare(conn)
+
+	var frames []byte
+	for i, request := range []string{"a", "b", "c", "d"} {
+		msg, err := codec.NewPacket(codec.NewJsonCodec()).EncodeWith(1, int32(i), request)
+		assert.Nil(t, err)
+		frame := make([]byte, 4+len(msg))
+		binary.BigEndian.PutUint32(frame, uint32(len(frame)))
+		copy(frame[4:], msg)
+		frames = append(frames, frame...)
+	}
+
+	// the frames arrived together are handled by one event, the partial one is kept for the next event
+	_, err := client.Write(frames[:len(frames)-3])
+	assert.Nil(t, err)
+	time.Sleep(50 * time.Millisecond)
+	instance.HandleRequest(conn)
+	// the requests are computed by the workers concurrently
+	assert.ElementsMatch(t, []string{"a", "b", "c"}, []string{<-received, <-received, <-received})
+	assert.Empty(t, received)
+
+	_, err = client.Write(frames[len(frames)-3:])
+	assert.Nil(t, err)
+	time.Sleep(50 * time.Millisecond)
+	instance.HandleRequest(conn)
+	assert.Equal(t, "d", <-received)
+}
+
+func TestThread_HandleWebsocketRequest(t *testing.T) {
+	instance := NewThread(defaultThreadOptions())
+	defer instance.Stop()
+
+	received := make(chan string, 10)
+	instance.Use(func(ctx *Context) {
+		var request string
+		assert.Nil(t, ctx.Bind(&request))
+		received <- request
+	})
+
+	server, client := tcpPair(t)
+	defer client.Close()
+	conn := NewConnection(codec.NewWebsocketDecoder(server), -1)
+	instance.prepare(conn)
+
+	frame := func(fin bool, op ws.OpCode, payload []byte) []byte {
+		var buf bytes.Buffer
+		assert.Nil(t, ws.WriteFrame(&buf, ws.MaskFrameInPlace(ws.NewFrame(op, fin, payload))))
+		return buf.Bytes()
+	}
+	var frames []byte
+	for _, request := range []string{"a", "b"} {
+		msg, err := codec.NewPacket(codec.NewJsonCodec()).EncodeWith(1, 1, request)
+		assert.Nil(t, err)
+		frames = append(frames, frame(true, ws.OpBinary, msg)...)
+	}
+	// the fragmented message with a ping between the fragments
+	msg, err := codec.NewPacket(codec.NewJsonCodec()).EncodeWith(1, 1, "c")
+	assert.Nil(t, err)
+	frames = append(frames, frame(false, ws.OpBinary, msg[:3])...)
+	frames = append(frames, frame(true, ws.OpPing, nil)...)
+	frames = append(frames, frame(true, ws.OpContinuation, msg[3:])...)
+
+	_, err = client.Write(frames[:len(frames)-2])
+	assert.Nil(t, err)
+	time.Sleep(50 * time.Millisecond)
+	instance.HandleRequest(conn)
+	assert.ElementsMatch(t, []string{"a", "b"}, []string{<-received, <-received})
+	assert.Empty(t, received)
+
+	_, err = client.Write(frames[len(frames)-2:])
+	assert.Nil(t, err)
+	time.Sleep(50 * time.Millisecond)
+	instance.HandleRequest(conn)
+	assert.Equal(t, "c", <-received)
+
+	// the pong is replied
+	hdr, err := ws.ReadHeader(client)
+	assert.Nil(t, err)
+	assert.Equal(t, ws.OpPong, hdr.OpCode)
+}
+
+// tcpPair returns the connected tcp connections, the server side one is closed when test finished
+func tcpPair(t *testing.T) (server, client net.Conn) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer ln.Close()
+
+	client, err = net.Dial("tcp", ln.Addr().String())
+	assert.Nil(t, err)
+	server, err = ln.Accept()
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = server.Close() })
+	return
+}
+
+func TestThread_Drain(t *testing.T) {
+	instance := NewThread(defaultThreadOptions())
+	assert.True(t, instance.Drain(time.Second))
+	instance.Stop()
+}
+
+func TestThread_ServeConnection(t *testing.T) {
+	instance := NewThread(defaultThreadOptions())
+	defer instance.Stop()
+
+	received := make(chan int32, 2)
+	instance.Use(func(ctx *Context) {
+		received <- ctx.Packet().Action
+	})
+
+	server, client := net.Pipe()
+	conn := NewConnection(codec.NewLengthFieldBasedFromDecoder(server, 4), -1)
+	instance.prepare(conn)
+	done := make(chan struct{})
+	go func() {
+		instance.ServeConnection(conn)
+		close(done)
+	}()
+
+	sender := codec.NewLengthFieldBasedFromDecoder(client, 4)
+	for _, action := range []int32{1, 2} {
+		msg, err := codec.NewPacket(codec.NewJsonCodec()).EncodeWith(action, 0, nil)
+		assert.Nil(t, err)
+		_, err = sender.Write(msg)
+		assert.Nil(t, err)
+		assert.Equal(t, action, <-received)
+	}
+
+	// the loop exits after the peer closed
+	_ = client.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("ServeConnection is not stopped")
+	}
+}
+
+func TestThread_ServeDatagramTooLarge(t *testing.T) {
+	instance := NewThread(defaultThreadOptions())
+	defer instance.Stop()
+	instance.metrics = NewMetrics(metrics.NewRegistry())
+	received := make(chan int32, 1)
+	instance.Use(func(ctx *Context) {
+		received <- ctx.Packet().Action
+	})
+
+	udp := acceptor.NewAcceptor(acceptor.NewUDPSchema("127.0.0.1:18122"), acceptor.DefaultOptions())
+	assert.Nil(t, udp.Listen(func(session net.Conn) {
+		conn := NewConnection(session, -1)
+		instance.prepare(conn)
+		go instance.ServeConnection(conn)
+	}))
+	defer udp.Shutdown()
+
+	client, err := net.Dial("udp", "127.0.0.1:18122")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// the datagram larger than the read buffer is dropped without closing the session
+	_, err = client.Write(make([]byte, defaultThreadOptions().MaxReadBufferSize+1))
+	assert.Nil(t, err)
+	msg, err := codec.NewPacket(codec.NewJsonCodec()).EncodeWith(1, 1, nil)
+	assert.Nil(t, err)
+	_, err = client.Write(msg)
+	assert.Nil(t, err)
+
+	select {
+	case action := <-received:
+		assert.Equal(t, int32(1), action)
+	case <-time.After(time.Second):
+		t.Fatal("the session is closed by the oversized datagram")
+	}
+	assert.Equal(t, int64(1), instance.metrics.decodeFailures.Count())
+}
+
+// finishingConn records the requests finished without response
+type finishingConn struct {
+	net.Conn
+	finished chan int32
+}
+
+func (conn *finishingConn) Finish(header []byte) {
+	packet := codec.NewPacket(codec.NewJsonCodec())
+	if packet.Unpack(header) == nil {
+		conn.finished <- packet.Action
+	}
+}
+
+func TestThread_Finish(t *testing.T) {
+	instance := NewThread(defaultThreadOptions())
+	defer instance.Stop()
+	router := NewRouter()
+	router.Route(1, func(ctx *Context) (any, error) {
+		return "pong", nil
+	})
+	router.Route(2, func(ctx *Context) (any, error) {
+		return nil, errors.New("failed")
+	})
+	instance.Use(router.handleRequest(func(ctx *Context, err error) {}))
+
+	server, client := net.Pipe()
+	defer client.Close()
+	finished := make(chan int32, 3)
+	conn := NewConnection(&finishingConn{Conn: codec.NewLengthFieldBasedFromDecoder(server, 4), finished: finished}, -1)
+	instance.prepare(conn)
+	go instance.ServeConnection(conn)
+
+	sender := codec.NewLengthFieldBasedFromDecoder(client, 4)
+	responses := make(chan int32, 3)
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, err := sender.Read(buf)
+			if err != nil {
+				return
+			}
+			packet := codec.NewPacket(codec.NewJsonCodec())
+			if packet.Unpack(buf[:n]) == nil {
+				responses <- packet.Action
+			}
+		}
+	}()
+
+	// the failed request and the request of unknown action are finished, the answered one is not
+	for _, action := range []int32{1, 2, 3} {
+		msg, err := codec.NewPacket(codec.NewJsonCodec()).EncodeWith(action, 1, nil)
+		assert.Nil(t, err)
+		_, err = sender.Write(msg)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, int32(1), <-responses)
+	assert.ElementsMatch(t, []int32{2, 3}, []int32{<-finished, <-finished})
+	assert.Empty(t, finished)
+}
+
+func TestThread_PacketOptions(t *testing.T) {
+	options := defaultThreadOptions()
+	options.Packet = &codec.Options{
+		ByteOrder:  binary.LittleEndian,
+		ActionSize: 4,
+		SeqSize:    4,
+		Fields:     []codec.Field{{Name: "version", Size: 1}, {Name: "trace", Size: 8}},
+	}
+	instance := NewThread(options)
+	defer instance.Stop()
+
+	// the packet refers to the read buffer, which is recycled after handled
+	received := make(chan []uint64, 1)
+	instance.Use(func(ctx *Context) {
+		packet := ctx.Packet()
+		assert.Nil(t, packet.Field("missing"))
+		received <- []uint64{uint64(packet.Action), uint64(packet.Seq), packet.FieldUint("version"), packet.FieldUint("trace")}
+	})
+
+	server, client := net.Pipe()
+	conn := NewConnection(codec.NewLengthFieldBasedFromDecoder(server, 4), -1)
+	instance.prepare(conn)
+	go instance.ServeConnection(conn)
+	defer client.Close()
+
+	packet := codec.NewPacketWithOptions(codec.NewJsonCodec(), options.Packet)
+	assert.Nil(t, packet.SetFieldUint("version", 2))
+	assert.Nil(t, packet.SetFieldUint("trace", 1<<40))
+	assert.NotNil(t, packet.SetField("trace", []byte{1}))
+	msg, err := packet.EncodeWith(70000, 100000, map[string]string{"foo": "bar"})
+	assert.Nil(t, err)
+	_, err = codec.NewLengthFieldBasedFromDecoder(client, 4).Write(msg)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []uint64{70000, 100000, 2, 1 << 40}, <-received)
+}
+
+func TestThread_Negotiate(t *testing.T) {
+	options := defaultThreadOptions()
+	options.NegotiateAction = 100
+	instance := NewThread(options)
+	defer instance.Stop()
+
+	received := make(chan string, 1)
+	instance.Use(func(ctx *Context) {
+		var request string
+		assert.Nil(t, ctx.Bind(&request))
+		received <- request
+	})
+
+	server, client := net.Pipe()
+	conn := NewConnection(codec.NewLengthFieldBasedFromDecoder(server, 4), -1)
+	instance.prepare(conn)
+	go instance.ServeConnection(conn)
+	defer client.Close()
+
+	sender := codec.NewLengthFieldBasedFromDecoder(client, 4)
+	msg, err := codec.NewPacket(codec.NewRawCodec()).EncodeWith(100, 1, codec.ContentTypeCBOR)
+	assert.Nil(t, err)
+	_, err = sender.Write(msg)
+	assert.Nil(t, err)
+
+	// the content type in use is replied
+	buf := make([]byte, 512)
+	n, err := sender.Read(buf)
+	assert.Nil(t, err)
+	reply := codec.NewPacket(codec.NewRawCodec())
+	assert.Nil(t, reply.Unpack(buf[:n]))
+	assert.Equal(t, codec.ContentTypeCBOR, string(reply.Body))
+	assert.Equal(t, codec.ContentTypeCBOR, conn.ContentType())
+
+	msg, err = codec.NewPacket(codec.NewCBORCodec()).EncodeWith(1, 2, "hello")
+	assert.Nil(t, err)
+	_, err = sender.Write(msg)
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", <-received)
 }
@@ -5,7 +5,11 @@ import (
 	"github.com/ebar-go/ego/component"
 	"github.com/ebar-go/ego/utils/runtime"
 	"github.com/ebar-go/znet/acceptor"
+	"github.com/ebar-go/znet/codec"
+	"github.com/gobwas/ws"
 	"log"
+	"net/http"
+	"sync"
 )
 
 // Network socket server master
@@ -15,6 +19,9 @@ type Network struct {
 	reactor   *Reactor // reactor model
 	thread    *Thread  //
 	callback  *Callback
+	groups    *GroupManager
+	heartbeat *Heartbeat
+	metrics   *Metrics
 	acceptors []acceptor.Instance
 }
 
@@ -22,15 +29,44 @@ type Network struct {
 func New(setters ...Option) *Network {
 	options := completeOptions(setters...)
 
+	// the metrics are shared by the thread, router and connections
+	m := options.NewMetrics()
+	thread := options.NewThread()
+	thread.metrics = m
+	router := options.NewRouter()
+	router.metrics = m
+
 	return &Network{
-		options:  options,
-		reactor:  options.NewReactorOrDie(),
-		router:   options.NewRouter(),
-		thread:   options.NewThread(),
-		callback: options.NewCallback(),
+		options:   options,
+		reactor:   options.NewReactorOrDie(),
+		router:    router,
+		thread:    thread,
+		callback:  options.NewCallback(),
+		groups:    NewGroupManager(),
+		heartbeat: options.NewHeartbeat(),
+		metrics:   m,
 	}
 }
 
+// Listen listens on the schema like tcp://:8081, the protocol must be registered to the acceptor package,
+// the options of the acceptor are copied from Options.Acceptor and modified by opts
+func (instance *Network) Listen(schema string, opts ...acceptor.Option) error {
+	s, err := acceptor.ParseSchema(schema)
+	if err != nil {
+		return err
+	}
+	options := instance.options.Acceptor
+	for _, setter := range opts {
+		setter(&options)
+	}
+	item, err := acceptor.New(s, options)
+	if err != nil {
+		return err
+	}
+	instance.acceptors = append(instance.acceptors, item)
+	return nil
+}
+
 // ListenTCP listens for tcp connections
 func (instance *Network) ListenTCP(addr string) {
 	instance.acceptors = append(instance.acceptors, acceptor.NewAcceptor(
@@ -45,6 +81,20 @@ func (instance *Network) ListenWebsocket(addr string) {
 		instance.options.Acceptor))
 }
 
+// ListenTLS listens for tcp connections over tls, Options.Acceptor.TLSConfig is required
+func (instance *Network) ListenTLS(addr string) {
+	instance.acceptors = append(instance.acceptors, acceptor.NewAcceptor(
+		acceptor.NewTLSSchema(addr),
+		instance.options.Acceptor))
+}
+
+// ListenWebsocketTLS listens for websocket connections over tls, Options.Acceptor.TLSConfig is required
+func (instance *Network) ListenWebsocketTLS(addr string) {
+	instance.acceptors = append(instance.acceptors, acceptor.NewAcceptor(
+		acceptor.NewWSSSchema(addr),
+		instance.options.Acceptor))
+}
+
 // ListenQUIC listens for quic connections
 func (instance *Network) ListenQUIC(addr string) {
 	instance.acceptors = append(instance.acceptors, acceptor.NewAcceptor(
@@ -52,81 +102,198 @@ func (instance *Network) ListenQUIC(addr string) {
 		instance.options.Acceptor))
 }
 
+// ListenUnix listens for unix domain socket connections on the path, the stale socket file is removed
+func (instance *Network) ListenUnix(path string) {
+	instance.acceptors = append(instance.acceptors, acceptor.NewAcceptor(
+		acceptor.NewUnixSchema(path),
+		instance.options.Acceptor))
+}
+
+// ListenUDP listens for udp datagrams, the remote addresses are mapped to virtual connections,
+// every datagram is handled as a packet
+func (instance *Network) ListenUDP(addr string) {
+	instance.acceptors = append(instance.acceptors, acceptor.NewAcceptor(
+		acceptor.NewUDPSchema(addr),
+		instance.options.Acceptor))
+}
+
+// WebsocketHandler returns the handler which serves websocket connections on an existing http server,
+// the path is the pattern which the handler is mounted on
+func (instance *Network) WebsocketHandler(path string) http.Handler {
+	handler := acceptor.NewWebsocketHandler(acceptor.NewWebSocketSchema(path), instance.options.Acceptor)
+	instance.acceptors = append(instance.acceptors, handler)
+	return handler
+}
+
 // Router return instance of Router
 func (instance *Network) Router() *Router {
 	return instance.router
 }
 
+// Groups return instance of GroupManager
+func (instance *Network) Groups() *GroupManager {
+	return instance.groups
+}
+
+// Metrics return instance of Metrics
+func (instance *Network) Metrics() *Metrics {
+	return instance.metrics
+}
+
+// Connections return instance of ConnectionManager
+func (instance *Network) Connections() *ConnectionManager {
+	return instance.reactor.connections
+}
+
 // Run starts the event-loop
 func (instance *Network) Run(stopCh <-chan struct{}) error {
 	if err := instance.options.Validate(); err != nil {
 		return err
 	}
+	if err := instance.router.validate(instance.options.Thread.Packet); err != nil {
+		return err
+	}
 	if len(instance.acceptors) == 0 {
 		return errors.New("there are no acceptor available")
 	}
+	// the gauge is registered while running, so the registry can be reused after stopped
+	if err := instance.metrics.watchQueue(instance.thread.QueueDepth); err != nil {
+		return err
+	}
+	defer instance.metrics.unwatchQueue()
 
+	instance.thread.Use(instance.heartbeat.handlePing)
 	instance.thread.Use(instance.options.Middlewares...)
 	instance.thread.Use(instance.router.handleRequest(instance.callback.onError))
 
 	component.Event().Trigger(BeforeServerStart, nil)
 	// start listeners
-	listenerSignal := make(chan struct{})
-	defer close(listenerSignal)
-	if err := instance.startAcceptor(listenerSignal); err != nil {
+	if err := instance.startAcceptor(); err != nil {
+		instance.stopAcceptor()
 		return err
 	}
 
 	// start reactor
 	reactorSignal := make(chan struct{})
-	defer close(reactorSignal)
+	reactorDone := make(chan struct{})
 	go func() {
 		defer runtime.HandleCrash()
+		defer close(reactorDone)
 		instance.reactor.Run(reactorSignal, instance.thread.HandleRequest)
 	}()
 
+	// start idle detection
+	if instance.heartbeat.Enabled() {
+		heartbeatSignal := make(chan struct{})
+		defer close(heartbeatSignal)
+		go func() {
+			defer runtime.HandleCrash()
+			instance.heartbeat.Run(heartbeatSignal)
+		}()
+	}
+
 	component.Event().Trigger(AfterServerStart, nil)
 
 	runtime.WaitClose(stopCh, func() {
 		component.Event().Trigger(BeforeServerShutdown, nil)
-	}, instance.shutdown)
+	}, func() {
+		instance.shutdown()
+
+		// stop reactor and wait for the poller closed
+		close(reactorSignal)
+		<-reactorDone
+
+		component.Event().Trigger(AfterServerShutdown, nil)
+	})
 
 	return nil
 }
 
 // =====================private methods =================
-func (instance *Network) startAcceptor(signal <-chan struct{}) error {
-	handler := instance.reactor.initializeConnection(
-		instance.callback.onOpen,
-		instance.callback.onClose,
-	)
-	unsupportedHandler := instance.reactor.initializeUnSupportedReactorConnection(
-		instance.callback.onOpen,
-		instance.callback.onClose,
-		instance.thread.HandleRequest,
-	)
-
+func (instance *Network) startAcceptor() error {
 	// prepare servers
 	for _, item := range instance.acceptors {
 		if item.ReactorSupported() {
+			handler := instance.reactor.initializeConnection(
+				item.Schema(),
+				instance.onOpen,
+				instance.callback.onClose,
+			)
 			if err := item.Listen(handler); err != nil {
 				return err
 			}
 		} else {
+			unsupportedHandler := instance.reactor.initializeUnSupportedReactorConnection(
+				item.Schema(),
+				instance.onOpen,
+				instance.callback.onClose,
+				instance.thread.ServeConnection,
+			)
 			if err := item.Listen(unsupportedHandler); err != nil {
 				return err
 			}
 		}
 
 		log.Printf("Start listener: %v\n", item.Schema())
-
-		// listen with context and connection register callback function
-		go runtime.WaitClose(signal, item.Shutdown)
-
 	}
 	return nil
 }
 
+func (instance *Network) stopAcceptor() {
+	for _, item := range instance.acceptors {
+		item.Shutdown()
+	}
+}
+
+// onOpen prepares the connection then invokes the open callback
+func (instance *Network) onOpen(conn *Connection) {
+	conn.readTimeout = instance.options.Acceptor.ReadDeadline
+	conn.writeTimeout = instance.options.Acceptor.WriteDeadline
+	instance.thread.prepare(conn)
+	conn.metrics = instance.metrics
+	instance.metrics.trackConnection(conn)
+	conn.serveOutbound(instance.options.Connection)
+	instance.heartbeat.Track(conn)
+	instance.callback.onOpen(conn)
+}
+
+// shutdown stops accepting, drains the in-flight requests and closes all connections
 func (instance *Network) shutdown() {
-	component.Event().Trigger(AfterServerShutdown, nil)
+	instance.stopAcceptor()
+
+	if action := instance.options.Shutdown.GoingAwayAction; action != 0 {
+		instance.goingAway(action)
+	}
+
+	if !instance.thread.Drain(instance.options.Shutdown.Timeout) {
+		log.Println("timeout waiting for in-flight requests")
+	}
+	instance.thread.Stop()
+
+	// close hooks are invoked by Close(), the websocket clients receive the going away status,
+	// the connections are closed concurrently so that the queued messages are flushed within one FlushTimeout
+	var wg sync.WaitGroup
+	instance.Connections().Iterator(func(conn *Connection) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn.CloseWithStatus(uint16(ws.StatusGoingAway), "server shutdown")
+		}()
+	})
+	wg.Wait()
+}
+
+// goingAway notify every connection that server is going away
+func (instance *Network) goingAway(action int32) {
+	packet := codec.NewPacketWithOptions(nil, instance.options.Thread.Packet)
+	packet.Action = action
+	msg, err := packet.Pack()
+	if err != nil {
+		return
+	}
+
+	// it is flushed before the connection closed, the connection whose queue is full is too slow to receive it
+	instance.Connections().Iterator(func(conn *Connection) {
+		_ = conn.tryEnqueue(msg)
+	})
 }
@@ -2,7 +2,10 @@ package znet
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"github.com/ebar-go/ego/utils/pool"
+	"github.com/ebar-go/znet/acceptor"
 	"github.com/ebar-go/znet/client"
 	"github.com/ebar-go/znet/codec"
 	"github.com/rcrowley/go-metrics"
@@ -33,6 +36,7 @@ func TestNew(t *testing.T) {
 			log.Printf("[%s] error: %v", ctx.Conn().ID(), err)
 		}
 		options.Acceptor.ReusePort = true
+		options.Acceptor.QUIC.SelfSigned = true
 	})
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
@@ -102,14 +106,14 @@ func TestClient(t *testing.T) {
 	})
 
 	t.Run("QUICClient", func(t *testing.T) {
-		conn, err := client.DialQUIC("127.0.0.1:8083")
+		conn, err := client.DialQUIC("127.0.0.1:8083", client.WithTLSConfig(&tls.Config{InsecureSkipVerify: true}))
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 		fn(conn)
 	})
 	t.Run("QUICClient2", func(t *testing.T) {
-		conn, err := client.DialQUIC("127.0.0.1:8083")
+		conn, err := client.DialQUIC("127.0.0.1:8083", client.WithTLSConfig(&tls.Config{InsecureSkipVerify: true}))
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -188,3 +192,63 @@ func BenchmarkClient(b *testing.B) {
 	}
 	select {}
 }
+
+func TestNetwork_Metrics(t *testing.T) {
+	registry := metrics.NewRegistry()
+	instance := New(WithMetricsRegistry(registry))
+	assert.Equal(t, registry, instance.Metrics().Registry())
+	instance.ListenTCP("127.0.0.1:18120")
+	instance.Router().Route(1, func(ctx *Context) (any, error) {
+		return "pong", nil
+	})
+
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.Nil(t, instance.Run(stopCh))
+	}()
+	defer func() {
+		close(stopCh)
+		<-done
+	}()
+
+	var (
+		c   *client.Client
+		err error
+	)
+	for i := 0; i < 50; i++ {
+		if c, err = client.DialTCP("127.0.0.1:18120"); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Nil(t, err)
+	defer c.Close()
+
+	var response string
+	assert.Nil(t, c.Call(context.Background(), 1, "ping", &response))
+	assert.Equal(t, "pong", response)
+
+	assert.Equal(t, int64(1), registry.Get("connections.accepted[tcp://127.0.0.1:18120]").(metrics.Counter).Count())
+	assert.Equal(t, int64(1), registry.Get("connections.active[tcp://127.0.0.1:18120]").(metrics.Counter).Count())
+	assert.Equal(t, int64(1), registry.Get("packets.in").(metrics.Meter).Count())
+	// the response may be received before it is recorded
+	assert.Eventually(t, func() bool {
+		return registry.Get("packets.out").(metrics.Meter).Count() == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Greater(t, registry.Get("bytes.in").(metrics.Meter).Count(), int64(0))
+	assert.Equal(t, int64(1), registry.Get("router.latency[1]").(metrics.Timer).Count())
+	assert.Equal(t, int64(0), registry.Get("worker.queue").(metrics.Gauge).Value())
+}
+
+func TestNetwork_Listen(t *testing.T) {
+	instance := New()
+	assert.Nil(t, instance.Listen("tcp://:18100", func(options *acceptor.Options) {
+		options.Core = 1
+	}))
+	assert.Equal(t, 1, len(instance.acceptors))
+	assert.Equal(t, errors.New("unsupported protocol: http"), instance.Listen("http://:18101"))
+	assert.NotNil(t, instance.Listen(":18101"))
+	assert.Equal(t, 1, len(instance.acceptors))
+}